@@ -7,7 +7,6 @@ toolchain go1.24.11
 require (
 	github.com/briandowns/spinner v1.23.2
 	github.com/pkoukk/tiktoken-go v0.1.8
-	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06
 	github.com/thxrsxm/rnbw v0.3.0
 	golang.org/x/term v0.37.0
 )
@@ -4,38 +4,260 @@ package acc
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/common"
 	"github.com/thxrsxm/harzmind-code/internal/logger"
+	"github.com/thxrsxm/harzmind-code/internal/modelinfo"
 	"github.com/thxrsxm/harzmind-code/internal/output"
 	"github.com/thxrsxm/rnbw"
 )
 
 // Account represents a user account with API credentials and model information.
+// Provider selects the API type (see api.Providers); an empty value means OpenAI-compatible.
+// NumCtx sets the context window requested from Ollama (0 uses the server default).
+// ContextWindow overrides the known context window of the model (0 uses the built-in registry).
+// The Azure fields describe an Azure OpenAI resource, its default deployment and the `api-version`.
+// The remaining fields configure the HTTP transport used for every API call of the account.
 type Account struct {
-	Name   string `json:"name"`
-	ApiUrl string `json:"apiUrl"`
-	ApiKey string `json:"apiKey"`
-	Model  string `json:"model"`
+	Name               string            `json:"name"`
+	Provider           string            `json:"provider,omitempty"`
+	ApiUrl             string            `json:"apiUrl"`
+	ApiKey             string            `json:"apiKey"`
+	Model              string            `json:"model"`
+	NumCtx             int               `json:"numCtx,omitempty"`
+	ContextWindow      int               `json:"contextWindow,omitempty"`
+	AzureResource      string            `json:"azureResource,omitempty"`
+	AzureDeployment    string            `json:"azureDeployment,omitempty"`
+	AzureApiVersion    string            `json:"azureApiVersion,omitempty"`
+	Timeout            int               `json:"timeout,omitempty"`
+	Proxy              string            `json:"proxy,omitempty"`
+	CACert             string            `json:"caCert,omitempty"`
+	InsecureSkipVerify bool              `json:"insecureSkipVerify,omitempty"`
+	Headers            map[string]string `json:"headers,omitempty"`
 }
 
+// headerKeyPrefix is the prefix of setting keys that address an extra HTTP header.
+const headerKeyPrefix = "header."
+
 // NewAccount creates a new Account instance with the given parameters.
-func NewAccount(name, apiUrl, apiKey, model string) *Account {
+func NewAccount(name, provider, apiUrl, apiKey, model string) *Account {
 	return &Account{
-		Name:   name,
-		ApiUrl: apiUrl,
-		ApiKey: apiKey,
-		Model:  model,
+		Name:     name,
+		Provider: provider,
+		ApiUrl:   apiUrl,
+		ApiKey:   apiKey,
+		Model:    model,
+	}
+}
+
+// GetProvider returns the provider type of the account, defaulting to OpenAI-compatible.
+func (a Account) GetProvider() string {
+	if len(a.Provider) == 0 {
+		return api.PROVIDER_OPENAI
+	}
+	return a.Provider
+}
+
+// GetModel returns the model of the account.
+// For Azure OpenAI the default deployment is used if no model is set.
+func (a Account) GetModel() string {
+	if len(a.Model) == 0 && a.GetProvider() == api.PROVIDER_AZURE {
+		return a.AzureDeployment
+	}
+	return a.Model
+}
+
+// GetContextWindow returns the context window of the account's model in tokens, or 0 if it is unknown.
+func (a Account) GetContextWindow() int {
+	return a.ContextWindowFor(a.GetModel())
+}
+
+// ContextWindowFor returns the context window of a model of the account in tokens, or 0 if it is unknown.
+// An explicit setting of the account wins. Ollama uses num_ctx or its server default,
+// all other providers the built-in registry of well-known models.
+func (a Account) ContextWindowFor(model string) int {
+	if a.ContextWindow > 0 {
+		return a.ContextWindow
+	}
+	if a.GetProvider() == api.PROVIDER_OLLAMA {
+		if a.NumCtx > 0 {
+			return a.NumCtx
+		}
+		return api.OLLAMA_DEFAULT_NUM_CTX
+	}
+	window, _ := modelinfo.LookupContextWindow(model)
+	return window
+}
+
+// Endpoint returns the API endpoint described by the account.
+func (a Account) Endpoint() api.Endpoint {
+	return api.Endpoint{
+		Provider:           a.GetProvider(),
+		URL:                a.ApiUrl,
+		Token:              a.ApiKey,
+		NumCtx:             a.NumCtx,
+		APIVersion:         a.AzureApiVersion,
+		Deployment:         a.AzureDeployment,
+		Timeout:            time.Duration(a.Timeout) * time.Second,
+		Proxy:              a.Proxy,
+		CACert:             a.CACert,
+		InsecureSkipVerify: a.InsecureSkipVerify,
+		Headers:            a.Headers,
 	}
 }
 
+// Set changes a setting of the account by key.
+// Supported keys:
+//   - `numctx`: context window requested from Ollama
+//   - `context`: context window of the model in tokens
+//   - `deployment`: default Azure OpenAI deployment
+//   - `apiversion`: Azure OpenAI `api-version`
+//   - `timeout`: request timeout in seconds
+//   - `proxy`: URL of the HTTP(S) proxy
+//   - `ca`: path of a PEM bundle with additional trusted certificates
+//   - `insecure`: `true` disables TLS certificate verification
+//   - `header.<name>`: extra HTTP header sent with every request
+func (a *Account) Set(key, value string) error {
+	if name, ok := strings.CutPrefix(key, headerKeyPrefix); ok && len(name) > 0 {
+		if a.Headers == nil {
+			a.Headers = map[string]string{}
+		}
+		a.Headers[name] = value
+		return nil
+	}
+	switch strings.ToLower(key) {
+	case "numctx":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid context window '%s'", value)
+		}
+		a.NumCtx = n
+	case "context":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid context window '%s'", value)
+		}
+		a.ContextWindow = n
+	case "deployment":
+		a.setDeployment(value)
+	case "apiversion":
+		a.AzureApiVersion = value
+	case "timeout":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid timeout '%s'", value)
+		}
+		a.Timeout = n
+	case "proxy":
+		if !common.IsValidURL(value) {
+			return fmt.Errorf("invalid proxy url")
+		}
+		a.Proxy = value
+	case "ca":
+		if !common.FileExists(value) {
+			return fmt.Errorf("file %s not found", value)
+		}
+		a.CACert = value
+	case "insecure":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean '%s'", value)
+		}
+		a.InsecureSkipVerify = b
+	default:
+		return fmt.Errorf("unknown setting '%s'", key)
+	}
+	return nil
+}
+
+// Unset resets a setting of the account (see Set) to its default.
+func (a *Account) Unset(key string) error {
+	if name, ok := strings.CutPrefix(key, headerKeyPrefix); ok && len(name) > 0 {
+		if _, exists := a.Headers[name]; !exists {
+			return fmt.Errorf("header '%s' not set", name)
+		}
+		delete(a.Headers, name)
+		return nil
+	}
+	switch strings.ToLower(key) {
+	case "numctx":
+		a.NumCtx = 0
+	case "context":
+		a.ContextWindow = 0
+	case "deployment":
+		a.setDeployment("")
+	case "apiversion":
+		a.AzureApiVersion = ""
+	case "timeout":
+		a.Timeout = 0
+	case "proxy":
+		a.Proxy = ""
+	case "ca":
+		a.CACert = ""
+	case "insecure":
+		a.InsecureSkipVerify = false
+	default:
+		return fmt.Errorf("unknown setting '%s'", key)
+	}
+	return nil
+}
+
+// setDeployment changes the default Azure OpenAI deployment.
+// Accounts created with the deployment as model follow the change.
+func (a *Account) setDeployment(deployment string) {
+	if a.Model == a.AzureDeployment {
+		a.Model = ""
+	}
+	a.AzureDeployment = deployment
+}
+
 // String returns a string representation of the Account instance.
+// Header values are omitted since they may contain credentials.
 func (a Account) String() string {
-	return fmt.Sprintf("Name: %s\nAPI Url: %s\nModel: %s",
+	str := fmt.Sprintf("Name: %s\nProvider: %s\nAPI Url: %s\nModel: %s",
 		a.Name,
+		a.GetProvider(),
 		a.ApiUrl,
-		a.Model,
+		a.GetModel(),
 	)
+	if a.NumCtx > 0 {
+		str += fmt.Sprintf("\nContext window (num_ctx): %d", a.NumCtx)
+	}
+	if a.ContextWindow > 0 {
+		str += fmt.Sprintf("\nContext window: %d", a.ContextWindow)
+	}
+	if len(a.AzureResource) > 0 {
+		str += fmt.Sprintf("\nResource: %s", a.AzureResource)
+	}
+	if len(a.AzureDeployment) > 0 {
+		str += fmt.Sprintf("\nDeployment: %s", a.AzureDeployment)
+	}
+	if len(a.AzureApiVersion) > 0 {
+		str += fmt.Sprintf("\nAPI version: %s", a.AzureApiVersion)
+	}
+	if a.Timeout > 0 {
+		str += fmt.Sprintf("\nTimeout: %ds", a.Timeout)
+	}
+	if len(a.Proxy) > 0 {
+		str += fmt.Sprintf("\nProxy: %s", a.Proxy)
+	}
+	if len(a.CACert) > 0 {
+		str += fmt.Sprintf("\nCA bundle: %s", a.CACert)
+	}
+	if a.InsecureSkipVerify {
+		str += "\nTLS verification: disabled"
+	}
+	if len(a.Headers) > 0 {
+		names := slices.Sorted(maps.Keys(a.Headers))
+		str += fmt.Sprintf("\nHeaders: %s", strings.Join(names, ", "))
+	}
+	return str
 }
 
 // AccountManager manages a collection of accounts and tracks the currently logged-in account.
@@ -170,6 +392,11 @@ func (m *AccountManager) PrintAllAccounts() {
 //   - `login <name>`
 //   - `remove <name>`
 //   - `info <name>`
+//   - `unset <key>`: resets a setting of the current account (see Account.Unset)
+//
+// Supported three-word commands:
+//   - `set <key> <value>`: changes a setting of the current account (see Account.Set),
+//     the value may contain spaces
 func (m *AccountManager) HandleCommands(input string) error {
 	if len(input) == 0 {
 		m.PrintAllAccounts()
@@ -237,6 +464,50 @@ func (m *AccountManager) HandleCommands(input string) error {
 				return err
 			}
 			return nil
+		case "unset":
+			// Reset a setting of the current account
+			account, err := m.GetCurrentAccount()
+			if err != nil {
+				return err
+			}
+			if err := account.Unset(args[1]); err != nil {
+				return err
+			}
+			if err := m.save(); err != nil {
+				return err
+			}
+			rnbw.ForegroundColor(rnbw.Green)
+			output.Printf("Successfully unset '%s' for account '%s'\n", args[1], account.Name)
+			rnbw.ResetColor()
+			logger.Log(logger.INFO, "unset '%s' for account '%s'", args[1], account.Name)
+			return nil
+		default:
+			return fmt.Errorf("command not found")
+		}
+	} else if len(args) >= 3 {
+		// The value is the remainder of the input and may contain spaces
+		args = strings.SplitN(input, " ", 3)
+		if len(args[1]) == 0 || len(args[2]) == 0 {
+			return fmt.Errorf("argument is missing")
+		}
+		switch args[0] {
+		case "set":
+			// Change a setting of the current account
+			account, err := m.GetCurrentAccount()
+			if err != nil {
+				return err
+			}
+			if err := account.Set(args[1], args[2]); err != nil {
+				return err
+			}
+			if err := m.save(); err != nil {
+				return err
+			}
+			rnbw.ForegroundColor(rnbw.Green)
+			output.Printf("Successfully set '%s' for account '%s'\n", args[1], account.Name)
+			rnbw.ResetColor()
+			logger.Log(logger.INFO, "set '%s' for account '%s'", args[1], account.Name)
+			return nil
 		default:
 			return fmt.Errorf("command not found")
 		}
@@ -0,0 +1,46 @@
+package acc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/thxrsxm/harzmind-code/internal/api"
+)
+
+func TestSetDeployment(t *testing.T) {
+	// Created by the wizard
+	account := newAzureAccount("azure", api.AzureResourceURL("my-resource"), "my-resource", "gpt-4o", api.AZURE_DEFAULT_API_VERSION, "key")
+	if got := account.GetModel(); got != "gpt-4o" {
+		t.Errorf("GetModel() = %q, want %q", got, "gpt-4o")
+	}
+	if err := account.Set("deployment", "gpt-4.1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := account.GetModel(); got != "gpt-4.1" {
+		t.Errorf("GetModel() after Set = %q, want %q", got, "gpt-4.1")
+	}
+	// Created with the deployment as model
+	legacy := NewAccount("azure", api.PROVIDER_AZURE, api.AzureResourceURL("my-resource"), "key", "gpt-4o")
+	legacy.AzureDeployment = "gpt-4o"
+	if err := legacy.Set("deployment", "gpt-4.1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := legacy.GetModel(); got != "gpt-4.1" {
+		t.Errorf("GetModel() of legacy account = %q, want %q", got, "gpt-4.1")
+	}
+	// A model chosen with /model is kept
+	account.Model = "o3"
+	if err := account.Set("deployment", "gpt-4o"); err != nil {
+		t.Fatal(err)
+	}
+	if got := account.GetModel(); got != "o3" {
+		t.Errorf("GetModel() with model = %q, want %q", got, "o3")
+	}
+}
+
+func TestStringShowsDeployment(t *testing.T) {
+	account := newAzureAccount("azure", api.AzureResourceURL("my-resource"), "my-resource", "gpt-4o", api.AZURE_DEFAULT_API_VERSION, "key")
+	if got := account.String(); !strings.Contains(got, "Model: gpt-4o\n") {
+		t.Errorf("String() = %q, want the deployment as model", got)
+	}
+}
@@ -2,16 +2,22 @@ package acc
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
+	"github.com/thxrsxm/harzmind-code/internal/api"
 	"github.com/thxrsxm/harzmind-code/internal/common"
 	"github.com/thxrsxm/harzmind-code/internal/input"
 )
 
 // handleAccountCreation prompts the user interactively to input account details.
-// It reads: name, API URL, API token (securely), and optionally model.
+// It reads: name, provider, API URL, API token (securely), and optionally model.
+// Provider and API URL fall back to OpenAI-compatible and the provider's default URL.
 // Returns a pointer to a new Account, or an error if validation fails.
 //
+// For Ollama the token is optional and the context window (num_ctx) is asked for.
+// Azure OpenAI accounts are created by handleAzureAccountCreation.
+//
 // NOTE: The wizard expects valid input: non-empty name/token, valid URL.
 func handleAccountCreation() (*Account, error) {
 	fmt.Println("Create account")
@@ -25,24 +31,49 @@ func handleAccountCreation() (*Account, error) {
 	if strings.TrimSpace(name) == "" {
 		return nil, fmt.Errorf("name cannot be empty")
 	}
+	// Read and validate provider type
+	fmt.Printf("Provider (%s) [%s]: ", strings.Join(api.Providers, ", "), api.PROVIDER_OPENAI)
+	provider, err := input.ReadInput(false)
+	if err != nil {
+		return nil, err
+	}
+	provider = strings.ToLower(provider)
+	if len(provider) == 0 {
+		provider = api.PROVIDER_OPENAI
+	}
+	if !slices.Contains(api.Providers, provider) {
+		return nil, fmt.Errorf("unknown provider '%s'", provider)
+	}
+	// Azure OpenAI derives the API URL from resource and deployment
+	if provider == api.PROVIDER_AZURE {
+		return handleAzureAccountCreation(name)
+	}
 	// Read and validate API URL
-	fmt.Print("API Url: ")
+	fmt.Printf("API Url [%s]: ", api.DefaultURL(provider))
 	apiURL, err := input.ReadInput(false)
 	if err != nil {
 		return nil, err
 	}
+	if len(apiURL) == 0 {
+		apiURL = api.DefaultURL(provider)
+	}
 	// Validate API URL
 	if !common.IsValidURL(apiURL) {
 		return nil, fmt.Errorf("invalid api url")
 	}
-	// Securely read API key/token (no echo)
-	fmt.Print("API Token: ")
+	// Securely read API key/token (no echo), optional for local providers
+	tokenOptional := api.TokenOptional(provider)
+	if tokenOptional {
+		fmt.Print("API Token (optional): ")
+	} else {
+		fmt.Print("API Token: ")
+	}
 	apiKey, err := input.ReadPassword()
 	if err != nil {
 		return nil, err
 	}
 	// Check for empty API token
-	if strings.TrimSpace(apiKey) == "" {
+	if strings.TrimSpace(apiKey) == "" && !tokenOptional {
 		return nil, fmt.Errorf("api token cannot be empty")
 	}
 	// Optionally read model (defaults to empty)
@@ -51,7 +82,74 @@ func handleAccountCreation() (*Account, error) {
 	if err != nil {
 		return nil, err
 	}
-	// Build and return the new account
-	account := NewAccount(name, apiURL, apiKey, model)
+	// Build the new account
+	account := NewAccount(name, provider, apiURL, apiKey, model)
+	// Optionally read the context window for Ollama
+	if provider == api.PROVIDER_OLLAMA {
+		fmt.Print("Context window (num_ctx, optional): ")
+		numCtx, err := input.ReadInput(false)
+		if err != nil {
+			return nil, err
+		}
+		if len(numCtx) > 0 {
+			if err := account.Set("numctx", numCtx); err != nil {
+				return nil, err
+			}
+		}
+	}
 	return account, nil
 }
+
+// handleAzureAccountCreation prompts for the details of an Azure OpenAI account.
+// It reads: resource (name or URL), deployment, API version and API key (securely).
+// The API URL is derived from the resource and the deployment is the default model.
+func handleAzureAccountCreation(name string) (*Account, error) {
+	// Read and validate resource
+	fmt.Print("Resource (name or URL): ")
+	resource, err := input.ReadInput(false)
+	if err != nil {
+		return nil, err
+	}
+	apiURL := api.AzureResourceURL(resource)
+	if len(resource) == 0 || !common.IsValidURL(apiURL) {
+		return nil, fmt.Errorf("invalid resource")
+	}
+	// Read and validate deployment
+	fmt.Print("Deployment: ")
+	deployment, err := input.ReadInput(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(deployment) == 0 {
+		return nil, fmt.Errorf("deployment cannot be empty")
+	}
+	// Read API version (defaults to the latest supported version)
+	fmt.Printf("API version [%s]: ", api.AZURE_DEFAULT_API_VERSION)
+	apiVersion, err := input.ReadInput(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiVersion) == 0 {
+		apiVersion = api.AZURE_DEFAULT_API_VERSION
+	}
+	// Securely read API key (no echo)
+	fmt.Print("API Key: ")
+	apiKey, err := input.ReadPassword()
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(apiKey) == "" {
+		return nil, fmt.Errorf("api key cannot be empty")
+	}
+	return newAzureAccount(name, apiURL, resource, deployment, apiVersion, apiKey), nil
+}
+
+// newAzureAccount creates an Azure OpenAI account. The model is left empty,
+// so that the default deployment is used (see Account.GetModel) and can be changed with `deployment`.
+func newAzureAccount(name, apiURL, resource, deployment, apiVersion, apiKey string) *Account {
+	account := NewAccount(name, api.PROVIDER_AZURE, apiURL, apiKey, "")
+	account.AzureResource = resource
+	account.AzureDeployment = deployment
+	account.AzureApiVersion = apiVersion
+	return account
+}
@@ -0,0 +1,161 @@
+// Package agent implements the tools the model can call in agent mode.
+//
+// Instead of sending the whole codebase with every message, the model receives
+// the file tree and reads, searches and changes the project on demand through
+// tool calls. File access is restricted to the project directory and the paths
+// that are not ignored; writing files and running commands require confirmation.
+package agent
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/executor"
+	"github.com/thxrsxm/harzmind-code/internal/policy"
+)
+
+// Names of the tools.
+const (
+	TOOL_READ_FILE   string = "read_file"
+	TOOL_LIST_DIR    string = "list_dir"
+	TOOL_GREP        string = "grep"
+	TOOL_WRITE_FILE  string = "write_file"
+	TOOL_RUN_COMMAND string = "run_command"
+)
+
+// maxResultLength is the maximum length of a tool result in bytes, longer results are truncated.
+const maxResultLength int = 32 * 1024
+
+// Instructions describes agent mode to the model. It is added to the system prompt.
+const Instructions string = `## Tools
+
+You do not see the contents of the project files up front, only the file tree below.
+Use the tools to read, search and list files before answering. To change a file, call
+write_file with its complete new content. Use run_command to build or test the project.
+Paths are relative to the project root. Answer without calling a tool once you are done.`
+
+// Tools are the definitions of all tools sent to the model.
+var Tools []api.Tool = []api.Tool{
+	newTool(TOOL_READ_FILE, "Read a file of the project. Optionally only the lines start_line to end_line (1-based, inclusive).",
+		map[string]any{
+			"path":       stringParam("Path of the file relative to the project root."),
+			"start_line": integerParam("First line to read."),
+			"end_line":   integerParam("Last line to read."),
+		}, "path"),
+	newTool(TOOL_LIST_DIR, "List the files and directories of a directory of the project. Directories end with '/'.",
+		map[string]any{
+			"path": stringParam("Path of the directory relative to the project root, '.' for the root."),
+		}),
+	newTool(TOOL_GREP, "Search the files of the project for a regular expression (RE2 syntax). Returns matching lines as path:line: text.",
+		map[string]any{
+			"pattern": stringParam("Regular expression to search for."),
+			"path":    stringParam("Only search files below this path (optional)."),
+		}, "pattern"),
+	newTool(TOOL_WRITE_FILE, "Create or overwrite a file of the project with the complete new content. The user reviews the change.",
+		map[string]any{
+			"path":    stringParam("Path of the file relative to the project root."),
+			"content": stringParam("Complete new content of the file."),
+		}, "path", "content"),
+	newTool(TOOL_RUN_COMMAND, "Run a shell command in the project and return its combined output. The command policy of the user may deny it.",
+		map[string]any{
+			"command": stringParam("The command, executed with bash -c."),
+			"cwd":     stringParam("Working directory relative to the project root (optional, the root by default)."),
+		}, "command"),
+}
+
+// Env is the environment in which the tools run commands.
+type Env struct {
+	// Commands decides which commands may run.
+	Commands *policy.Checker
+	// Exec are the options of the command execution (timeout, output limit, isolation).
+	Exec executor.Options
+}
+
+// Execute runs the tool call and returns the result for the model.
+// Commands are checked against the command policy of env before they run.
+// Errors caused by the arguments or declined by the user are reported to the model as result,
+// only a cancellation of ctx or a failing prompt is returned as error.
+func Execute(ctx context.Context, call api.ToolCall, env Env) (string, error) {
+	var result string
+	var err error
+	switch call.Function.Name {
+	case TOOL_READ_FILE:
+		result, err = readFile(call)
+	case TOOL_LIST_DIR:
+		result, err = listDir(call)
+	case TOOL_GREP:
+		result, err = grep(call)
+	case TOOL_WRITE_FILE:
+		result, err = writeFile(call)
+	case TOOL_RUN_COMMAND:
+		result, err = runCommand(ctx, call, env)
+	default:
+		err = fmt.Errorf("unknown tool '%s'", call.Function.Name)
+	}
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err != nil {
+		if _, ok := err.(*promptError); ok {
+			return "", err
+		}
+		return "error: " + err.Error(), nil
+	}
+	return truncate(result), nil
+}
+
+// promptError wraps an error reading the answer of the user.
+type promptError struct {
+	err error
+}
+
+func (e *promptError) Error() string {
+	return e.err.Error()
+}
+
+func (e *promptError) Unwrap() error {
+	return e.err
+}
+
+// truncate shortens the result to at most maxResultLength bytes without splitting a UTF-8 character.
+func truncate(result string) string {
+	if len(result) <= maxResultLength {
+		return result
+	}
+	end := maxResultLength
+	for end > 0 && !utf8.RuneStart(result[end]) {
+		end--
+	}
+	return result[:end] + fmt.Sprintf("\n[truncated %d bytes]", len(result)-end)
+}
+
+// newTool creates the definition of a function tool with the parameters as JSON schema.
+func newTool(name, description string, properties map[string]any, required ...string) api.Tool {
+	parameters := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		parameters["required"] = required
+	}
+	return api.Tool{
+		Type: "function",
+		Function: api.ToolFunction{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		},
+	}
+}
+
+// stringParam returns the JSON schema of a string parameter.
+func stringParam(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+// integerParam returns the JSON schema of an integer parameter.
+func integerParam(description string) map[string]any {
+	return map[string]any{"type": "integer", "description": description}
+}
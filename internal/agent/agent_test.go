@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/policy"
+)
+
+// call creates a tool call with the arguments as JSON.
+func call(name, arguments string) api.ToolCall {
+	return api.ToolCall{ID: "call", Type: "function", Function: api.FunctionCall{Name: name, Arguments: arguments}}
+}
+
+func TestExecute(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.MkdirAll(filepath.Join("pkg", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"main.go":             "package main\n\nfunc main() {\n\tpkg.Run()\n}\n",
+		"pkg/run.go":          "package pkg\n\nfunc Run() {}\n",
+		"node_modules/x/x.js": "Run()\n",
+		"data.bin":            "\x00\x01\x02",
+		"big.txt":             strings.Repeat("a\n", 256*1024),
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name string
+		call api.ToolCall
+		want string
+	}{
+		{"Read file", call(TOOL_READ_FILE, `{"path":"pkg/run.go"}`), "package pkg\n\nfunc Run() {}\n"},
+		{"Read lines", call(TOOL_READ_FILE, `{"path":"main.go","start_line":3,"end_line":4}`), "func main() {\n\tpkg.Run()\n"},
+		{"Read outside", call(TOOL_READ_FILE, `{"path":"../secret"}`), "error: path ../secret is outside of the project"},
+		{"Read ignored", call(TOOL_READ_FILE, `{"path":"node_modules/x/x.js"}`), "error: path node_modules/x/x.js is ignored"},
+		{"Read binary", call(TOOL_READ_FILE, `{"path":"data.bin"}`), "error: data.bin is binary (3 bytes)"},
+		{"Read too large", call(TOOL_READ_FILE, `{"path":"big.txt"}`), "error: big.txt is too large (524288 bytes)"},
+		{"List root", call(TOOL_LIST_DIR, `{"path":"."}`), "big.txt\ndata.bin\nmain.go\npkg/\n"},
+		{"List dir", call(TOOL_LIST_DIR, `{"path":"pkg"}`), "pkg/run.go\npkg/sub/\n"},
+		{"Grep", call(TOOL_GREP, `{"pattern":"Run\\(\\)"}`), "main.go:4: \tpkg.Run()\npkg/run.go:3: func Run() {}\n"},
+		{"Grep path", call(TOOL_GREP, `{"pattern":"Run","path":"pkg"}`), "pkg/run.go:3: func Run() {}\n"},
+		{"Grep no match", call(TOOL_GREP, `{"pattern":"Stop"}`), "no matches"},
+		{"Unknown tool", call("delete_file", `{}`), "error: unknown tool 'delete_file'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Execute(context.Background(), tt.call, Env{Commands: policy.NewChecker()})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Execute() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	got := truncate(strings.Repeat("a", maxResultLength+10))
+	if !strings.HasSuffix(got, "\n[truncated 10 bytes]") || len(got) != maxResultLength+len("\n[truncated 10 bytes]") {
+		t.Errorf("truncate() returned %d bytes", len(got))
+	}
+	// The cut must not split the 3-byte character at the limit
+	got = truncate(strings.Repeat("a", maxResultLength-1) + strings.Repeat("€", 4))
+	if !utf8.ValidString(got) || !strings.HasSuffix(got, "\n[truncated 12 bytes]") {
+		t.Errorf("truncate() = %q", got[maxResultLength-10:])
+	}
+}
+
+func TestRunCommandCwd(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("pkg", 0755); err != nil {
+		t.Fatal(err)
+	}
+	env := Env{Commands: policy.NewChecker(policy.Policy{Default: policy.ALLOW})}
+	got, err := Execute(context.Background(), call(TOOL_RUN_COMMAND, `{"command":"basename $PWD","cwd":"pkg"}`), env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "pkg\n[exit status 0]" {
+		t.Errorf("run_command in pkg = %q", got)
+	}
+	got, err = Execute(context.Background(), call(TOOL_RUN_COMMAND, `{"command":"true","cwd":".."}`), env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "error: path .. is outside of the project" {
+		t.Errorf("run_command outside of the project = %q", got)
+	}
+}
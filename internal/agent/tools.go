@@ -0,0 +1,203 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/codebase"
+	"github.com/thxrsxm/harzmind-code/internal/edits"
+	"github.com/thxrsxm/harzmind-code/internal/executor"
+	"github.com/thxrsxm/harzmind-code/internal/policy"
+)
+
+// maxMatches is the maximum number of lines returned by grep.
+const maxMatches int = 200
+
+// readFile returns the content of a file, optionally limited to a range of lines.
+// Binary files and files beyond the file limit of the codebase are refused (see codebase.ReadFile).
+func readFile(call api.ToolCall) (string, error) {
+	var args struct {
+		Path      string `json:"path"`
+		StartLine int    `json:"start_line"`
+		EndLine   int    `json:"end_line"`
+	}
+	if err := call.DecodeArguments(&args); err != nil {
+		return "", err
+	}
+	path, err := edits.ValidatePath(args.Path)
+	if err != nil {
+		return "", err
+	}
+	f, skip, err := codebase.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if skip != nil {
+		return "", fmt.Errorf("%s is %s (%d bytes)", args.Path, skip.Reason, skip.Size)
+	}
+	if args.StartLine <= 0 && args.EndLine <= 0 {
+		return f.Content, nil
+	}
+	lines := strings.SplitAfter(f.Content, "\n")
+	start := max(args.StartLine, 1)
+	end := len(lines)
+	if args.EndLine > 0 {
+		end = min(args.EndLine, len(lines))
+	}
+	if start > end {
+		return "", fmt.Errorf("invalid line range %d-%d of %s (%d lines)", args.StartLine, args.EndLine, args.Path, len(lines))
+	}
+	return strings.Join(lines[start-1:end], ""), nil
+}
+
+// listDir lists the entries of a directory that are not ignored.
+func listDir(call api.ToolCall) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := call.DecodeArguments(&args); err != nil {
+		return "", err
+	}
+	dir, err := projectPath(args.Path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if codebase.IsIgnored(path) {
+			continue
+		}
+		sb.WriteString(filepath.ToSlash(path))
+		if entry.IsDir() {
+			sb.WriteString("/")
+		}
+		sb.WriteString("\n")
+	}
+	if sb.Len() == 0 {
+		return "(empty directory)", nil
+	}
+	return sb.String(), nil
+}
+
+// grep searches the codebase files below a path for a regular expression.
+func grep(call api.ToolCall) (string, error) {
+	var args struct {
+		Pattern string `json:"pattern"`
+		Path    string `json:"path"`
+	}
+	if err := call.DecodeArguments(&args); err != nil {
+		return "", err
+	}
+	re, err := regexp.Compile(args.Pattern)
+	if err != nil {
+		return "", err
+	}
+	dir, err := projectPath(args.Path)
+	if err != nil {
+		return "", err
+	}
+	files, err := codebase.GetCodeBase(".")
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	matches := 0
+	for _, file := range files {
+		if dir != "." && file.Path != dir && !strings.HasPrefix(file.Path, dir+string(filepath.Separator)) {
+			continue
+		}
+		for i, line := range strings.Split(file.Content, "\n") {
+			if !re.MatchString(line) {
+				continue
+			}
+			if matches == maxMatches {
+				sb.WriteString(fmt.Sprintf("[more than %d matches, narrow the search]\n", maxMatches))
+				return sb.String(), nil
+			}
+			sb.WriteString(fmt.Sprintf("%s:%d: %s\n", filepath.ToSlash(file.Path), i+1, strings.TrimRight(line, "\r")))
+			matches++
+		}
+	}
+	if matches == 0 {
+		return "no matches", nil
+	}
+	return sb.String(), nil
+}
+
+// writeFile shows the diff of the new file content and writes it after confirmation.
+func writeFile(call api.ToolCall) (string, error) {
+	var args struct {
+		Path    string `json:"path"`
+		Content string `json:"content"`
+	}
+	if err := call.DecodeArguments(&args); err != nil {
+		return "", err
+	}
+	if _, err := edits.ValidatePath(args.Path); err != nil {
+		return "", err
+	}
+	applied, err := edits.ReviewEdits([]edits.Edit{{Path: args.Path, Content: args.Content}})
+	if err != nil {
+		return "", &promptError{err}
+	}
+	if len(applied) == 0 {
+		return "the user did not apply the change", nil
+	}
+	return fmt.Sprintf("wrote %s", args.Path), nil
+}
+
+// runCommand runs a shell command in the project root or the working directory of the call
+// if the policy allows it and returns its output
+// followed by the exit status, truncation and isolation notes in brackets.
+func runCommand(ctx context.Context, call api.ToolCall, env Env) (string, error) {
+	var args struct {
+		Command string `json:"command"`
+		Cwd     string `json:"cwd"`
+	}
+	if err := call.DecodeArguments(&args); err != nil {
+		return "", err
+	}
+	if len(strings.TrimSpace(args.Command)) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+	opts := env.Exec
+	if len(args.Cwd) > 0 {
+		dir, err := projectPath(args.Cwd)
+		if err != nil {
+			return "", err
+		}
+		opts.Dir = dir
+	}
+	if err := env.Commands.Confirm(args.Command); err != nil {
+		var denied *policy.DeniedError
+		if errors.As(err, &denied) {
+			return denied.Error(), nil
+		}
+		return "", &promptError{err}
+	}
+	res, err := executor.Run(ctx, args.Command, opts)
+	policy.Record("agent", args.Command, res.ExitCode, err)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(res.Output, "\n") + "\n[" + strings.ReplaceAll(res.Report(), "\n", "]\n[") + "]", nil
+}
+
+// projectPath validates a directory path of the project, empty and "." denote the root.
+func projectPath(path string) (string, error) {
+	if len(path) == 0 || filepath.Clean(path) == "." {
+		return ".", nil
+	}
+	return edits.ValidatePath(path)
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// anthropicVersion is the value of the required `anthropic-version` header.
+	anthropicVersion = "2023-06-01"
+	// anthropicMaxTokens is the maximum number of tokens to generate per response.
+	anthropicMaxTokens = 8192
+)
+
+// anthropicRequest represents a request to the Anthropic Messages API.
+type anthropicRequest struct {
+	Model     string    `json:"model"`
+	MaxTokens int       `json:"max_tokens"`
+	System    string    `json:"system,omitempty"`
+	Messages  []Message `json:"messages"`
+	Stream    bool      `json:"stream,omitempty"`
+}
+
+// anthropicUsage represents the token usage of an Anthropic response.
+// The input tokens exclude the tokens read from or written to the prompt cache.
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// anthropicResponse represents a response of the Anthropic Messages API.
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage *anthropicUsage `json:"usage"`
+}
+
+// anthropicStreamEvent represents a single event of a streamed Anthropic response.
+// The input usage is sent with `message_start`, the final output usage with `message_delta`.
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage *anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicModelsResponse represents a response of the Anthropic models endpoint.
+type anthropicModelsResponse struct {
+	Data []struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"display_name"`
+	} `json:"data"`
+}
+
+// anthropicProvider implements Provider for the Anthropic Messages API.
+type anthropicProvider struct {
+	endpoint Endpoint
+}
+
+// newAnthropicProvider creates a provider for an Anthropic Messages API endpoint.
+func newAnthropicProvider(endpoint Endpoint) *anthropicProvider {
+	return &anthropicProvider{endpoint: endpoint}
+}
+
+// SendMessage sends the messages to the Messages API.
+func (p *anthropicProvider) SendMessage(ctx context.Context, model string, messages []Message) (Reply, error) {
+	req, err := p.newMessagesRequest(ctx, model, messages, false)
+	if err != nil {
+		return Reply{}, err
+	}
+	var msgResp anthropicResponse
+	if err := receiveJSON(p.endpoint, req, &msgResp); err != nil {
+		return Reply{}, err
+	}
+	// Join all text content blocks
+	var content strings.Builder
+	for _, block := range msgResp.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	if content.Len() == 0 {
+		return Reply{}, fmt.Errorf("no content in response")
+	}
+	reply := Reply{Content: content.String()}
+	if msgResp.Usage != nil {
+		reply.Usage = msgResp.Usage.usage()
+	}
+	return reply, nil
+}
+
+// StreamMessage sends the messages to the Messages API with streaming enabled.
+func (p *anthropicProvider) StreamMessage(ctx context.Context, model string, messages []Message, onChunk func(string)) (Reply, error) {
+	req, err := p.newMessagesRequest(ctx, model, messages, true)
+	if err != nil {
+		return Reply{}, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := send(p.endpoint, req, true)
+	if err != nil {
+		return Reply{}, err
+	}
+	defer resp.Body.Close()
+	var content strings.Builder
+	var usage *Usage
+	err = readEvents(resp.Body, func(data string) (bool, error) {
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return false, fmt.Errorf("failed to unmarshal stream event: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			if event.Message.Usage != nil {
+				usage = event.Message.Usage.usage()
+			}
+		case "message_delta":
+			// The output tokens are cumulative
+			if event.Usage != nil && usage != nil {
+				usage.CompletionTokens = event.Usage.OutputTokens
+			}
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" || len(event.Delta.Text) == 0 {
+				return false, nil
+			}
+			content.WriteString(event.Delta.Text)
+			if onChunk != nil {
+				onChunk(event.Delta.Text)
+			}
+		case "message_stop":
+			return true, nil
+		case "error":
+			return false, fmt.Errorf("API error: %s - %s", event.Error.Type, event.Error.Message)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return Reply{Content: content.String()}, err
+	}
+	if content.Len() == 0 {
+		return Reply{}, fmt.Errorf("no content in response stream")
+	}
+	return Reply{Content: content.String(), Usage: usage}, nil
+}
+
+// GetModels lists the models of the `/v1/models` endpoint.
+func (p *anthropicProvider) GetModels(ctx context.Context) ([]string, error) {
+	// Create the models URL.
+	modelsURL := strings.TrimSuffix(p.endpoint.URL, "/messages") + "/models?limit=1000"
+	req, err := newJSONRequest(ctx, "GET", modelsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.setHeaders(req)
+	var modelsResp anthropicModelsResponse
+	if err := receiveJSON(p.endpoint, req, &modelsResp); err != nil {
+		return nil, err
+	}
+	models := []string{}
+	for _, model := range modelsResp.Data {
+		models = append(models, model.ID)
+	}
+	return models, nil
+}
+
+// newMessagesRequest creates the HTTP request for the Messages API.
+// System messages are moved into the top-level `system` field.
+func (p *anthropicProvider) newMessagesRequest(ctx context.Context, model string, messages []Message, stream bool) (*http.Request, error) {
+	reqBody := anthropicRequest{
+		Model:     model,
+		MaxTokens: anthropicMaxTokens,
+		Messages:  []Message{},
+		Stream:    stream,
+	}
+	system := []string{}
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			system = append(system, msg.Content)
+			continue
+		}
+		reqBody.Messages = append(reqBody.Messages, msg)
+	}
+	reqBody.System = strings.Join(system, "\n\n")
+	req, err := newJSONRequest(ctx, "POST", p.endpoint.URL, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	p.setHeaders(req)
+	return req, nil
+}
+
+// setHeaders sets the authentication and version headers.
+func (p *anthropicProvider) setHeaders(req *http.Request) {
+	req.Header.Set("x-api-key", p.endpoint.Token)
+	req.Header.Set("anthropic-version", anthropicVersion)
+}
+
+// usage converts the Anthropic usage to a Usage.
+// Cache reads and writes are counted as prompt tokens.
+func (a *anthropicUsage) usage() *Usage {
+	return &Usage{
+		PromptTokens:     a.InputTokens + a.CacheCreationInputTokens + a.CacheReadInputTokens,
+		CompletionTokens: a.OutputTokens,
+		CachedTokens:     a.CacheReadInputTokens,
+	}
+}
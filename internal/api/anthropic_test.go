@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// newAnthropicServer starts a stand-in for the Anthropic API that records the last messages request.
+func newAnthropicServer(t *testing.T, last *anthropicRequest) *httptest.Server {
+	t.Helper()
+	authorized := func(r *http.Request) bool {
+		return r.Header.Get("x-api-key") == "key" && r.Header.Get("anthropic-version") == anthropicVersion && r.Header.Get("Authorization") == ""
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/messages", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(last); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !last.Stream {
+			fmt.Fprint(w, `{"content":[{"type":"text","text":"Hello"},{"type":"tool_use","id":"t"},{"type":"text","text":" world"}],"usage":{"input_tokens":10,"output_tokens":2,"cache_read_input_tokens":5}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"usage\":{\"input_tokens\":10,\"output_tokens\":1}}}\n\n")
+		fmt.Fprint(w, "event: ping\ndata: {\"type\":\"ping\"}\n\n")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hel\"}}\n\n")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"lo\"}}\n\n")
+		fmt.Fprint(w, "event: message_delta\ndata: {\"type\":\"message_delta\",\"usage\":{\"output_tokens\":7}}\n\n")
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	})
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"claude-a","display_name":"A"},{"id":"claude-b","display_name":"B"}]}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAnthropicProvider(t *testing.T) {
+	var last anthropicRequest
+	server := newAnthropicServer(t, &last)
+	provider, err := NewProvider(Endpoint{Provider: PROVIDER_ANTHROPIC, URL: server.URL + "/v1/messages", Token: "key"})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	messages := []Message{
+		{Role: "system", Content: "system prompt"},
+		{Role: "system", Content: "summary of the conversation"},
+		{Role: "user", Content: "question"},
+		{Role: "assistant", Content: "answer"},
+		{Role: "user", Content: "follow-up"},
+	}
+
+	t.Run("SendMessage", func(t *testing.T) {
+		got, err := SendMessage(context.Background(), provider, "claude-test", messages)
+		if err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+		if got.Content != "Hello world" {
+			t.Errorf("SendMessage() = %q, want %q", got.Content, "Hello world")
+		}
+		if want := (Usage{PromptTokens: 15, CompletionTokens: 2, CachedTokens: 5}); got.Usage == nil || *got.Usage != want {
+			t.Errorf("SendMessage() usage = %+v, want %+v", got.Usage, want)
+		}
+		if want := "system prompt\n\nsummary of the conversation"; last.System != want {
+			t.Errorf("system = %q, want %q", last.System, want)
+		}
+		if !reflect.DeepEqual(last.Messages, messages[2:]) {
+			t.Errorf("messages = %+v, want %+v", last.Messages, messages[2:])
+		}
+		if last.Model != "claude-test" || last.MaxTokens != anthropicMaxTokens || last.Stream {
+			t.Errorf("request = %+v", last)
+		}
+	})
+
+	t.Run("StreamMessage", func(t *testing.T) {
+		chunks := []string{}
+		got, err := StreamMessage(context.Background(), provider, "claude-test", messages, func(chunk string) { chunks = append(chunks, chunk) })
+		if err != nil {
+			t.Fatalf("StreamMessage() error = %v", err)
+		}
+		if got.Content != "Hello" || !reflect.DeepEqual(chunks, []string{"Hel", "lo"}) {
+			t.Errorf("StreamMessage() = %q in chunks %q", got.Content, chunks)
+		}
+		if want := (Usage{PromptTokens: 10, CompletionTokens: 7}); got.Usage == nil || *got.Usage != want {
+			t.Errorf("StreamMessage() usage = %+v, want %+v", got.Usage, want)
+		}
+		if !last.Stream {
+			t.Errorf("stream was not requested")
+		}
+	})
+
+	t.Run("GetModels", func(t *testing.T) {
+		got, err := GetModels(context.Background(), provider)
+		if err != nil {
+			t.Fatalf("GetModels() error = %v", err)
+		}
+		if want := []string{"claude-a", "claude-b"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GetModels() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		provider, _ := NewProvider(Endpoint{Provider: PROVIDER_ANTHROPIC, URL: server.URL + "/v1/messages", Token: "wrong"})
+		if _, err := SendMessage(context.Background(), provider, "claude-test", messages); err == nil {
+			t.Errorf("SendMessage() expected error for unauthorized request")
+		}
+	})
+}
@@ -1,149 +1,268 @@
 // Package api handles communication with external Large Language Model APIs,
 // including sending messages, retrieving available models, and managing API requests.
+//
+// Every supported API is implemented as a Provider. The package-level functions
+// validate the input and delegate to the provider of the current account.
 package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 )
 
+const (
+	// PROVIDER_OPENAI is the provider type for OpenAI-compatible `/chat/completions` APIs.
+	PROVIDER_OPENAI string = "openai"
+	// PROVIDER_ANTHROPIC is the provider type for the Anthropic Messages API.
+	PROVIDER_ANTHROPIC string = "anthropic"
+	// PROVIDER_GEMINI is the provider type for the Google Gemini API.
+	PROVIDER_GEMINI string = "gemini"
+	// PROVIDER_OLLAMA is the provider type for the native Ollama API.
+	PROVIDER_OLLAMA string = "ollama"
+	// PROVIDER_AZURE is the provider type for Azure OpenAI deployments.
+	PROVIDER_AZURE string = "azure"
+)
+
+// Providers lists all supported provider types.
+var Providers []string = []string{
+	PROVIDER_OPENAI,
+	PROVIDER_ANTHROPIC,
+	PROVIDER_GEMINI,
+	PROVIDER_OLLAMA,
+	PROVIDER_AZURE,
+}
+
+// requestTimeout is the default time until a response (or its headers when streaming) arrives.
+const requestTimeout = 120 * time.Second
+
 // Message represents a message in the chat.
+// Assistant messages may request tool calls, whose results are sent back
+// as messages with the role `tool` and the ID of the call.
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// Usage reports the number of tokens processed by a request.
+type Usage struct {
+	// PromptTokens is the number of input tokens including the cached ones.
+	PromptTokens int `json:"promptTokens"`
+	// CompletionTokens is the number of generated tokens.
+	CompletionTokens int `json:"completionTokens"`
+	// CachedTokens is the number of input tokens read from the provider's prompt cache.
+	CachedTokens int `json:"cachedTokens"`
 }
 
-// ChatRequest represents a chat request.
-type ChatRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+// Add returns the sum of both usages.
+func (u Usage) Add(o Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + o.PromptTokens,
+		CompletionTokens: u.CompletionTokens + o.CompletionTokens,
+		CachedTokens:     u.CachedTokens + o.CachedTokens,
+	}
+}
+
+// Reply represents the response of the API to a chat request.
+type Reply struct {
+	// Content is the complete response content.
+	Content string
+	// Usage is the token usage reported by the API, nil if it was not reported.
+	Usage *Usage
+	// ToolCalls are the tool calls requested by the model (see ToolCaller).
+	ToolCalls []ToolCall
 }
 
-// ChatResponse represents a chat response.
-type ChatResponse struct {
-	Choices []struct {
-		Message Message `json:"message"`
-	} `json:"choices"`
+// Provider is implemented by every supported LLM API.
+type Provider interface {
+	// SendMessage sends the messages and returns the complete reply.
+	SendMessage(ctx context.Context, model string, messages []Message) (Reply, error)
+	// StreamMessage sends the messages with streaming enabled, calls onChunk for every
+	// content fragment and returns the assembled reply.
+	StreamMessage(ctx context.Context, model string, messages []Message, onChunk func(string)) (Reply, error)
+	// GetModels returns the IDs of all models available to the account.
+	GetModels(ctx context.Context) ([]string, error)
 }
 
-// ModelsResponse represents a models response.
-type ModelsResponse struct {
-	Data []struct {
-		ID      string `json:"id"`
-		Object  string `json:"object"`
-		Created int64  `json:"created"`
-		OwnedBy string `json:"owned_by"`
-	} `json:"data"`
+// Endpoint describes how to reach and authenticate against an API.
+type Endpoint struct {
+	// Provider is the provider type (see Providers). Empty means PROVIDER_OPENAI.
+	Provider string
+	// URL is the chat endpoint of the API (the API base URL for Gemini, Ollama and Azure).
+	URL string
+	// Token is the API key used for authentication.
+	Token string
+	// NumCtx is the context window size requested from Ollama (0 uses the server default).
+	NumCtx int
+	// APIVersion is the `api-version` of Azure OpenAI requests (empty uses the default).
+	APIVersion string
+	// Deployment is the configured Azure OpenAI deployment, listed if the deployments cannot be listed.
+	Deployment string
+	// Retry is the policy for retrying failed requests.
+	Retry RetryPolicy
+	// Timeout limits the time until a response (or its headers when streaming) arrives (0 uses the default).
+	Timeout time.Duration
+	// Proxy is the URL of the HTTP(S) proxy (empty uses the environment).
+	Proxy string
+	// CACert is the path of a PEM bundle with additional trusted certificates.
+	CACert string
+	// InsecureSkipVerify disables the verification of TLS certificates.
+	InsecureSkipVerify bool
+	// Headers are added to every request and override headers set by the provider.
+	Headers map[string]string
 }
 
-// SendMessage sends a message to the API and returns the response.
-// It takes the API URL, model, token, and messages as input.
-// It returns the response content and an error if any.
-func SendMessage(apiURL, model, token string, messages []Message) (string, error) {
+// NewProvider creates the provider for the given endpoint.
+// It returns an error if the provider type is unknown or the endpoint is incomplete.
+func NewProvider(endpoint Endpoint) (Provider, error) {
 	// Check if the URL is valid.
-	if len(apiURL) == 0 {
-		return "", fmt.Errorf("api url is not valid")
+	if len(endpoint.URL) == 0 {
+		return nil, fmt.Errorf("api url is not valid")
+	}
+	// Check if the token is valid (optional for Ollama).
+	if len(endpoint.Token) == 0 && !TokenOptional(endpoint.Provider) {
+		return nil, fmt.Errorf("API token is not valid")
+	}
+	switch endpoint.Provider {
+	case "", PROVIDER_OPENAI:
+		return newOpenAIProvider(endpoint), nil
+	case PROVIDER_ANTHROPIC:
+		return newAnthropicProvider(endpoint), nil
+	case PROVIDER_GEMINI:
+		return newGeminiProvider(endpoint), nil
+	case PROVIDER_OLLAMA:
+		return newOllamaProvider(endpoint), nil
+	case PROVIDER_AZURE:
+		return newAzureProvider(endpoint), nil
+	default:
+		return nil, fmt.Errorf("unknown provider '%s'", endpoint.Provider)
+	}
+}
+
+// DefaultURL returns the default chat endpoint of a provider type.
+func DefaultURL(provider string) string {
+	switch provider {
+	case "", PROVIDER_OPENAI:
+		return "https://api.openai.com/v1/chat/completions"
+	case PROVIDER_ANTHROPIC:
+		return "https://api.anthropic.com/v1/messages"
+	case PROVIDER_GEMINI:
+		return "https://generativelanguage.googleapis.com/v1beta"
+	case PROVIDER_OLLAMA:
+		return "http://localhost:11434"
+	default:
+		return ""
 	}
+}
+
+// TokenOptional reports whether the provider type can be used without an API token.
+func TokenOptional(provider string) bool {
+	return provider == PROVIDER_OLLAMA
+}
+
+// SendMessage sends a message to the API of the provider and returns the reply.
+// It takes the provider, model, and messages as input.
+// The request is aborted when ctx is cancelled.
+// It returns the reply and an error if any.
+func SendMessage(ctx context.Context, provider Provider, model string, messages []Message) (Reply, error) {
 	// Check if the model is valid.
 	if len(model) == 0 {
-		return "", fmt.Errorf("model is not valid")
-	}
-	// Check if the token is valid.
-	if len(token) == 0 {
-		return "", fmt.Errorf("API token is not valid")
+		return Reply{}, fmt.Errorf("model is not valid")
 	}
-	// Create a new chat request.
-	reqBody := ChatRequest{
-		Model:    model,
-		Messages: messages,
+	return provider.SendMessage(ctx, model, messages)
+}
+
+// StreamMessage sends a message to the API of the provider with streaming enabled.
+// It calls onChunk for every content fragment as soon as it arrives and
+// returns the fully assembled reply once the stream has ended.
+// The request is aborted when ctx is cancelled.
+func StreamMessage(ctx context.Context, provider Provider, model string, messages []Message, onChunk func(string)) (Reply, error) {
+	// Check if the model is valid.
+	if len(model) == 0 {
+		return Reply{}, fmt.Errorf("model is not valid")
 	}
-	// Marshal the request body to JSON.
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+	return provider.StreamMessage(ctx, model, messages, onChunk)
+}
+
+// GetModels gets the list of models from the API of the provider.
+// The request is aborted when ctx is cancelled.
+// It returns the list of models and an error if any.
+func GetModels(ctx context.Context, provider Provider) ([]string, error) {
+	return provider.GetModels(ctx)
+}
+
+// newJSONRequest creates an HTTP request with the JSON encoded body (if any).
+func newJSONRequest(ctx context.Context, method, url string, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		// Marshal the request body to JSON.
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(jsonData)
 	}
 	// Create a new HTTP request.
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	// Set the content type to JSON.
-	req.Header.Set("Content-Type", "application/json")
-	// Set the authorization header.
-	req.Header.Set("Authorization", "Bearer "+token)
-	// Add timeout to prevent hanging requests
-	client := &http.Client{Timeout: 120 * time.Second}
-	// Send the request and get the response.
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
-	defer resp.Body.Close()
-	// Read the response body.
-	body, err := io.ReadAll(resp.Body)
+	return req, nil
+}
+
+// send sends the request to the endpoint and returns the response if the API answered with status 200.
+// Otherwise the response body is read and returned as part of the error.
+// Failed requests are retried according to the retry policy of the endpoint.
+// Streaming requests only limit the time until the response headers arrive,
+// the stream itself may take longer than any fixed timeout.
+// The caller must close the body of the returned response.
+func send(endpoint Endpoint, req *http.Request, stream bool) (*http.Response, error) {
+	client, err := newClient(endpoint, stream)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("API error: %s - %s", resp.Status, string(body))
+	// Add the extra headers of the endpoint
+	for key, value := range endpoint.Headers {
+		req.Header.Set(key, value)
 	}
-	// Unmarshal the response to a ChatResponse.
-	var chatResp ChatResponse
-	err = json.Unmarshal(body, &chatResp)
+	// Send the request and get the response.
+	resp, err := doWithRetry(client, req, endpoint.Retry)
 	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		return nil, err
 	}
-	// Check if the response has any choices.
-	if len(chatResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error: %s - %s", resp.Status, string(body))
 	}
-	return chatResp.Choices[0].Message.Content, nil
+	return resp, nil
 }
 
-// GetModels gets the list of models from the API.
-// It takes the API URL and token as input.
-// It returns the list of models and an error if any.
-func GetModels(api, token string) ([]string, error) {
-	// Create the models URL.
-	modelsURL := strings.TrimSuffix(api, "/chat/completions") + "/models"
-	// Create a new HTTP request.
-	req, err := http.NewRequest("GET", modelsURL, nil)
+// receiveJSON sends the request to the endpoint and unmarshals the JSON response body into v.
+func receiveJSON(endpoint Endpoint, req *http.Request, v any) error {
+	resp, err := send(endpoint, req, false)
 	if err != nil {
-		return nil, err
-	}
-	// Set the authorization header.
-	req.Header.Set("Authorization", "Bearer "+token)
-	// Create a new HTTP client.
-	client := &http.Client{}
-	// Send the request and get the response.
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 	// Read the response body.
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("API Error: %s - %s", resp.Status, string(body))
-	}
-	// Unmarshal the response to a ModelsResponse.
-	var modelsResp ModelsResponse
-	err = json.Unmarshal(body, &modelsResp)
-	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to read response body: %w", err)
 	}
-	// Create a new list of models.
-	models := []string{}
-	for _, model := range modelsResp.Data {
-		models = append(models, model.ID)
+	// Unmarshal the response.
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
 	}
-	return models, nil
+	return nil
 }
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/thxrsxm/harzmind-code/internal/logger"
+)
+
+const (
+	// AZURE_DEFAULT_API_VERSION is the default `api-version` of the requests.
+	AZURE_DEFAULT_API_VERSION string = "2024-10-21"
+	// azureDeploymentsAPIVersion is the last GA `api-version` that supports listing deployments.
+	azureDeploymentsAPIVersion string = "2022-12-01"
+	// azureDeploymentsLastDate is the date of the last `api-version` that supports listing deployments.
+	azureDeploymentsLastDate string = "2023-03-15"
+)
+
+// newAzureProvider creates a provider for an Azure OpenAI resource.
+// The endpoint URL is the resource base (e.g. `https://my-resource.openai.azure.com`),
+// the model is the name of the deployment and requests authenticate with the `api-key` header.
+// The models listing is mapped to the deployments of the resource, with the `api-version` of the
+// endpoint if it supports the listing and otherwise with the last one that does. If the listing
+// fails, the configured deployment is listed instead.
+func newAzureProvider(endpoint Endpoint) *openAIProvider {
+	base := strings.TrimSuffix(endpoint.URL, "/") + "/openai/deployments"
+	apiVersion := endpoint.APIVersion
+	if len(apiVersion) == 0 {
+		apiVersion = AZURE_DEFAULT_API_VERSION
+	}
+	listVersion := apiVersion
+	if len(listVersion) < len(azureDeploymentsLastDate) || listVersion[:len(azureDeploymentsLastDate)] > azureDeploymentsLastDate {
+		listVersion = azureDeploymentsAPIVersion
+	}
+	return &openAIProvider{
+		endpoint: endpoint,
+		chatURL: func(model string) string {
+			return base + "/" + url.PathEscape(model) + "/chat/completions?api-version=" + url.QueryEscape(apiVersion)
+		},
+		modelsURL: base + "?api-version=" + url.QueryEscape(listVersion),
+		modelsFallback: func(err error) ([]string, error) {
+			if len(endpoint.Deployment) == 0 {
+				return nil, fmt.Errorf("listing the deployments with api-version %s failed (switch deployments with /model <deployment>): %w", listVersion, err)
+			}
+			logger.Log(logger.WARNING, "listing the deployments with api-version %s failed, listing the configured deployment: %v", listVersion, err)
+			return []string{endpoint.Deployment}, nil
+		},
+		auth: func(req *http.Request) {
+			req.Header.Set("api-key", endpoint.Token)
+		},
+	}
+}
+
+// AzureResourceURL returns the base URL of an Azure OpenAI resource.
+// The resource may be given by name or as full URL (e.g. for custom domains).
+func AzureResourceURL(resource string) string {
+	if strings.Contains(resource, "://") {
+		return strings.TrimSuffix(resource, "/")
+	}
+	return "https://" + resource + ".openai.azure.com"
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAzureProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api-key") != "key" || r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/openai/deployments/gpt-prod/chat/completions" && r.URL.Query().Get("api-version") == "2023-03-15-preview":
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Hello"}}]}`)
+		case r.Method == "GET" && r.URL.Path == "/openai/deployments" && r.URL.Query().Get("api-version") == "2023-03-15-preview":
+			fmt.Fprint(w, `{"data":[{"id":"gpt-prod","model":"gpt-4o"},{"id":"gpt-dev","model":"gpt-4o-mini"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// An api-version that supports the listing is used for it
+	provider, err := NewProvider(Endpoint{Provider: PROVIDER_AZURE, URL: server.URL, Token: "key", APIVersion: "2023-03-15-preview"})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	got, err := SendMessage(context.Background(), provider, "gpt-prod", []Message{{Role: "user", Content: "Hi"}})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if got.Content != "Hello" {
+		t.Errorf("SendMessage() = %q, want %q", got.Content, "Hello")
+	}
+	models, err := GetModels(context.Background(), provider)
+	if err != nil {
+		t.Fatalf("GetModels() error = %v", err)
+	}
+	if want := []string{"gpt-prod", "gpt-dev"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("GetModels() = %v, want %v", models, want)
+	}
+	// Newer api-versions list with the last one that supports it, a failed listing results in a clear error
+	provider, err = NewProvider(Endpoint{Provider: PROVIDER_AZURE, URL: server.URL, Token: "key", APIVersion: "2025-01-01"})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	if _, err := GetModels(context.Background(), provider); err == nil || !strings.Contains(err.Error(), "api-version "+azureDeploymentsAPIVersion+" failed") {
+		t.Errorf("GetModels() error = %v", err)
+	}
+	// ... or in the configured deployment if there is one
+	provider, err = NewProvider(Endpoint{Provider: PROVIDER_AZURE, URL: server.URL, Token: "key", APIVersion: "2025-01-01", Deployment: "gpt-prod"})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	models, err = GetModels(context.Background(), provider)
+	if err != nil || !reflect.DeepEqual(models, []string{"gpt-prod"}) {
+		t.Errorf("GetModels() = %v, %v, want the configured deployment", models, err)
+	}
+}
+
+func TestAzureDefaultAPIVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Query().Get("api-version") == AZURE_DEFAULT_API_VERSION:
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Hello"}}]}`)
+		case r.Method == "GET" && r.URL.Query().Get("api-version") == azureDeploymentsAPIVersion:
+			fmt.Fprint(w, `{"data":[{"id":"gpt-prod","model":"gpt-4o"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(Endpoint{Provider: PROVIDER_AZURE, URL: server.URL, Token: "key"})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	if _, err := SendMessage(context.Background(), provider, "gpt-prod", []Message{{Role: "user", Content: "Hi"}}); err != nil {
+		t.Errorf("SendMessage() error = %v", err)
+	}
+	models, err := GetModels(context.Background(), provider)
+	if err != nil || !reflect.DeepEqual(models, []string{"gpt-prod"}) {
+		t.Errorf("GetModels() = %v, %v, want the listed deployments", models, err)
+	}
+}
+
+func TestAzureResourceURL(t *testing.T) {
+	tests := []struct {
+		resource string
+		want     string
+	}{
+		{"my-resource", "https://my-resource.openai.azure.com"},
+		{"https://ai.example.com/", "https://ai.example.com"},
+	}
+
+	for _, tt := range tests {
+		if got := AzureResourceURL(tt.resource); got != tt.want {
+			t.Errorf("AzureResourceURL(%q) = %q, want %q", tt.resource, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// geminiPart represents a part of a Gemini content.
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+// geminiContent represents a message in the Gemini `contents` format.
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiRequest represents a `generateContent` request.
+type geminiRequest struct {
+	SystemInstruction *geminiContent  `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent `json:"contents"`
+}
+
+// geminiResponse represents a `generateContent` response or a chunk of a streamed response.
+type geminiResponse struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata *struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// geminiModelsResponse represents a `models.list` response.
+type geminiModelsResponse struct {
+	Models []struct {
+		Name                       string   `json:"name"`
+		SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+	} `json:"models"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// geminiProvider implements Provider for the Google Gemini API.
+// The endpoint URL is the API base, e.g. `https://generativelanguage.googleapis.com/v1beta`.
+type geminiProvider struct {
+	endpoint Endpoint
+}
+
+// newGeminiProvider creates a provider for a Gemini API endpoint.
+func newGeminiProvider(endpoint Endpoint) *geminiProvider {
+	return &geminiProvider{endpoint: endpoint}
+}
+
+// SendMessage sends the messages to the `generateContent` method of the model.
+func (p *geminiProvider) SendMessage(ctx context.Context, model string, messages []Message) (Reply, error) {
+	req, err := p.newGenerateRequest(ctx, model, messages, false)
+	if err != nil {
+		return Reply{}, err
+	}
+	var genResp geminiResponse
+	if err := receiveJSON(p.endpoint, req, &genResp); err != nil {
+		return Reply{}, err
+	}
+	content, err := genResp.text()
+	if err != nil {
+		return Reply{}, err
+	}
+	if len(content) == 0 {
+		return Reply{}, fmt.Errorf("no content in response")
+	}
+	return Reply{Content: content, Usage: genResp.usage()}, nil
+}
+
+// StreamMessage sends the messages to the `streamGenerateContent` method of the model.
+func (p *geminiProvider) StreamMessage(ctx context.Context, model string, messages []Message, onChunk func(string)) (Reply, error) {
+	req, err := p.newGenerateRequest(ctx, model, messages, true)
+	if err != nil {
+		return Reply{}, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := send(p.endpoint, req, true)
+	if err != nil {
+		return Reply{}, err
+	}
+	defer resp.Body.Close()
+	var content strings.Builder
+	var usage *Usage
+	err = readEvents(resp.Body, func(data string) (bool, error) {
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+		// Every chunk carries the usage so far, the last one is complete
+		if chunkUsage := chunk.usage(); chunkUsage != nil {
+			usage = chunkUsage
+		}
+		text, err := chunk.text()
+		if err != nil {
+			return false, err
+		}
+		if len(text) == 0 {
+			return false, nil
+		}
+		content.WriteString(text)
+		if onChunk != nil {
+			onChunk(text)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return Reply{Content: content.String()}, err
+	}
+	if content.Len() == 0 {
+		return Reply{}, fmt.Errorf("no content in response stream")
+	}
+	return Reply{Content: content.String(), Usage: usage}, nil
+}
+
+// GetModels lists all models that support `generateContent`, following all result pages.
+func (p *geminiProvider) GetModels(ctx context.Context) ([]string, error) {
+	models := []string{}
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"1000"}}
+		if len(pageToken) > 0 {
+			query.Set("pageToken", pageToken)
+		}
+		modelsURL, err := p.url("models", query)
+		if err != nil {
+			return nil, err
+		}
+		req, err := newJSONRequest(ctx, "GET", modelsURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		p.setHeaders(req)
+		var modelsResp geminiModelsResponse
+		if err := receiveJSON(p.endpoint, req, &modelsResp); err != nil {
+			return nil, err
+		}
+		for _, model := range modelsResp.Models {
+			if slices.Contains(model.SupportedGenerationMethods, "generateContent") {
+				models = append(models, strings.TrimPrefix(model.Name, "models/"))
+			}
+		}
+		if len(modelsResp.NextPageToken) == 0 {
+			return models, nil
+		}
+		pageToken = modelsResp.NextPageToken
+	}
+}
+
+// newGenerateRequest creates the HTTP request for the (streamed) content generation.
+// System messages become the `systemInstruction`, assistant messages are mapped to the `model` role.
+func (p *geminiProvider) newGenerateRequest(ctx context.Context, model string, messages []Message, stream bool) (*http.Request, error) {
+	reqBody := geminiRequest{Contents: []geminiContent{}}
+	system := []string{}
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			system = append(system, msg.Content)
+		case "assistant":
+			reqBody.Contents = append(reqBody.Contents, geminiContent{Role: "model", Parts: []geminiPart{{Text: msg.Content}}})
+		default:
+			reqBody.Contents = append(reqBody.Contents, geminiContent{Role: "user", Parts: []geminiPart{{Text: msg.Content}}})
+		}
+	}
+	if len(system) > 0 {
+		reqBody.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: strings.Join(system, "\n\n")}}}
+	}
+	// Build the method URL
+	method := ":generateContent"
+	query := url.Values{}
+	if stream {
+		method = ":streamGenerateContent"
+		query.Set("alt", "sse")
+	}
+	genURL, err := p.url("models/"+strings.TrimPrefix(model, "models/")+method, query)
+	if err != nil {
+		return nil, err
+	}
+	req, err := newJSONRequest(ctx, "POST", genURL, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	p.setHeaders(req)
+	return req, nil
+}
+
+// url joins the endpoint base URL with the given path and query parameters.
+// Query parameters of the base URL (e.g. `key`) are preserved.
+func (p *geminiProvider) url(path string, query url.Values) (string, error) {
+	u, err := url.Parse(p.endpoint.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid api url: %w", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + path
+	q := u.Query()
+	for k, v := range query {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// setHeaders sets the authentication header, unless the API key is already part of the URL.
+func (p *geminiProvider) setHeaders(req *http.Request) {
+	if req.URL.Query().Has("key") {
+		return
+	}
+	req.Header.Set("x-goog-api-key", p.endpoint.Token)
+}
+
+// text returns the joined text parts of the first candidate.
+// It returns an error if the prompt was blocked.
+func (r geminiResponse) text() (string, error) {
+	if len(r.PromptFeedback.BlockReason) > 0 {
+		return "", fmt.Errorf("prompt blocked: %s", r.PromptFeedback.BlockReason)
+	}
+	if len(r.Candidates) == 0 {
+		return "", nil
+	}
+	var content strings.Builder
+	for _, part := range r.Candidates[0].Content.Parts {
+		content.WriteString(part.Text)
+	}
+	return content.String(), nil
+}
+
+// usage returns the token usage of the response, nil if it was not reported.
+func (r geminiResponse) usage() *Usage {
+	if r.UsageMetadata == nil {
+		return nil
+	}
+	return &Usage{
+		PromptTokens:     r.UsageMetadata.PromptTokenCount,
+		CompletionTokens: r.UsageMetadata.CandidatesTokenCount,
+		CachedTokens:     r.UsageMetadata.CachedContentTokenCount,
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// newGeminiServer starts a stand-in for the Gemini API that records the last generate request.
+func newGeminiServer(t *testing.T, last *geminiRequest) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1beta/models/{method}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(last); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.PathValue("method") {
+		case "gemini-test:generateContent":
+			fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"},{"text":" world"}]}}],"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":2}}`)
+		case "gemini-test:streamGenerateContent":
+			if r.URL.Query().Get("alt") != "sse" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hel\"}]}}]}\r\n\r\n")
+			fmt.Fprint(w, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"lo\"}]}}]}\r\n\r\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("GET /v1beta/models", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pageToken") == "" {
+			fmt.Fprint(w, `{"models":[{"name":"models/gemini-a","supportedGenerationMethods":["generateContent"]},{"name":"models/embedding","supportedGenerationMethods":["embedContent"]}],"nextPageToken":"next"}`)
+			return
+		}
+		fmt.Fprint(w, `{"models":[{"name":"models/gemini-b","supportedGenerationMethods":["generateContent","countTokens"]}]}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGeminiProvider(t *testing.T) {
+	var last geminiRequest
+	server := newGeminiServer(t, &last)
+	provider, err := NewProvider(Endpoint{Provider: PROVIDER_GEMINI, URL: server.URL + "/v1beta", Token: "key"})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	messages := []Message{
+		{Role: "system", Content: "system prompt"},
+		{Role: "user", Content: "question"},
+		{Role: "assistant", Content: "answer"},
+		{Role: "user", Content: "follow-up"},
+	}
+
+	t.Run("SendMessage", func(t *testing.T) {
+		got, err := SendMessage(context.Background(), provider, "gemini-test", messages)
+		if err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+		if got.Content != "Hello world" {
+			t.Errorf("SendMessage() = %q, want %q", got.Content, "Hello world")
+		}
+		if want := (Usage{PromptTokens: 12, CompletionTokens: 2}); got.Usage == nil || *got.Usage != want {
+			t.Errorf("SendMessage() usage = %+v, want %+v", got.Usage, want)
+		}
+		wantSystem := &geminiContent{Parts: []geminiPart{{Text: "system prompt"}}}
+		if !reflect.DeepEqual(last.SystemInstruction, wantSystem) {
+			t.Errorf("systemInstruction = %+v, want %+v", last.SystemInstruction, wantSystem)
+		}
+		wantContents := []geminiContent{
+			{Role: "user", Parts: []geminiPart{{Text: "question"}}},
+			{Role: "model", Parts: []geminiPart{{Text: "answer"}}},
+			{Role: "user", Parts: []geminiPart{{Text: "follow-up"}}},
+		}
+		if !reflect.DeepEqual(last.Contents, wantContents) {
+			t.Errorf("contents = %+v, want %+v", last.Contents, wantContents)
+		}
+	})
+
+	t.Run("StreamMessage", func(t *testing.T) {
+		chunks := 0
+		got, err := StreamMessage(context.Background(), provider, "models/gemini-test", messages, func(string) { chunks++ })
+		if err != nil {
+			t.Fatalf("StreamMessage() error = %v", err)
+		}
+		if got.Content != "Hello" || chunks != 2 {
+			t.Errorf("StreamMessage() = %q in %d chunks, want %q in 2 chunks", got.Content, chunks, "Hello")
+		}
+	})
+
+	t.Run("GetModels", func(t *testing.T) {
+		got, err := GetModels(context.Background(), provider)
+		if err != nil {
+			t.Fatalf("GetModels() error = %v", err)
+		}
+		want := []string{"gemini-a", "gemini-b"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetModels() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		provider, _ := NewProvider(Endpoint{Provider: PROVIDER_GEMINI, URL: server.URL + "/v1beta", Token: "wrong"})
+		if _, err := SendMessage(context.Background(), provider, "gemini-test", messages); err == nil {
+			t.Errorf("SendMessage() expected error for unauthorized request")
+		}
+	})
+}
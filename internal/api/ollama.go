@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OLLAMA_DEFAULT_NUM_CTX is the context window Ollama uses if num_ctx is not set.
+// Longer prompts are silently truncated by the server.
+const OLLAMA_DEFAULT_NUM_CTX int = 4096
+
+// PullProgress reports the progress of a model download.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+}
+
+// ModelPuller is implemented by providers that can download models on demand.
+type ModelPuller interface {
+	// PullModel downloads the named model and calls onProgress for every progress update.
+	PullModel(ctx context.Context, name string, onProgress func(PullProgress)) error
+}
+
+// ollamaOptions represents the model options of an Ollama request.
+type ollamaOptions struct {
+	NumCtx int `json:"num_ctx,omitempty"`
+}
+
+// ollamaChatRequest represents a request to the Ollama `/api/chat` endpoint.
+type ollamaChatRequest struct {
+	Model    string         `json:"model"`
+	Messages []Message      `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Options  *ollamaOptions `json:"options,omitempty"`
+}
+
+// ollamaChatResponse represents a response (or a streamed line) of the `/api/chat` endpoint.
+// The token counts are only sent with the final response.
+type ollamaChatResponse struct {
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	Error           string  `json:"error"`
+	PromptEvalCount int     `json:"prompt_eval_count"`
+	EvalCount       int     `json:"eval_count"`
+}
+
+// ollamaTagsResponse represents a response of the `/api/tags` endpoint.
+type ollamaTagsResponse struct {
+	Models []struct {
+		Name string `json:"name"`
+	} `json:"models"`
+}
+
+// ollamaPullRequest represents a request to the `/api/pull` endpoint.
+type ollamaPullRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
+}
+
+// ollamaProvider implements Provider and ModelPuller for the native Ollama API.
+// The endpoint URL is the server base, e.g. `http://localhost:11434`.
+type ollamaProvider struct {
+	endpoint Endpoint
+}
+
+// newOllamaProvider creates a provider for an Ollama server.
+func newOllamaProvider(endpoint Endpoint) *ollamaProvider {
+	return &ollamaProvider{endpoint: endpoint}
+}
+
+// SendMessage sends the messages to the `/api/chat` endpoint.
+func (p *ollamaProvider) SendMessage(ctx context.Context, model string, messages []Message) (Reply, error) {
+	req, err := p.newChatRequest(ctx, model, messages, false)
+	if err != nil {
+		return Reply{}, err
+	}
+	var chatResp ollamaChatResponse
+	if err := receiveJSON(p.endpoint, req, &chatResp); err != nil {
+		return Reply{}, err
+	}
+	if len(chatResp.Error) > 0 {
+		return Reply{}, fmt.Errorf("API error: %s", chatResp.Error)
+	}
+	if len(chatResp.Message.Content) == 0 {
+		return Reply{}, fmt.Errorf("no content in response")
+	}
+	return Reply{Content: chatResp.Message.Content, Usage: chatResp.usage()}, nil
+}
+
+// StreamMessage sends the messages to the `/api/chat` endpoint and reads the NDJSON stream.
+func (p *ollamaProvider) StreamMessage(ctx context.Context, model string, messages []Message, onChunk func(string)) (Reply, error) {
+	req, err := p.newChatRequest(ctx, model, messages, true)
+	if err != nil {
+		return Reply{}, err
+	}
+	resp, err := send(p.endpoint, req, true)
+	if err != nil {
+		return Reply{}, err
+	}
+	defer resp.Body.Close()
+	var content strings.Builder
+	var usage *Usage
+	err = readLines(resp.Body, func(line string) (bool, error) {
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return false, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+		if len(chunk.Error) > 0 {
+			return false, fmt.Errorf("API error: %s", chunk.Error)
+		}
+		if len(chunk.Message.Content) > 0 {
+			content.WriteString(chunk.Message.Content)
+			if onChunk != nil {
+				onChunk(chunk.Message.Content)
+			}
+		}
+		if chunk.Done {
+			usage = chunk.usage()
+		}
+		return chunk.Done, nil
+	})
+	if err != nil {
+		return Reply{Content: content.String()}, err
+	}
+	if content.Len() == 0 {
+		return Reply{}, fmt.Errorf("no content in response stream")
+	}
+	return Reply{Content: content.String(), Usage: usage}, nil
+}
+
+// GetModels lists the locally available models of the `/api/tags` endpoint.
+func (p *ollamaProvider) GetModels(ctx context.Context) ([]string, error) {
+	req, err := newJSONRequest(ctx, "GET", p.url("/api/tags"), nil)
+	if err != nil {
+		return nil, err
+	}
+	p.setHeaders(req)
+	var tagsResp ollamaTagsResponse
+	if err := receiveJSON(p.endpoint, req, &tagsResp); err != nil {
+		return nil, err
+	}
+	models := []string{}
+	for _, model := range tagsResp.Models {
+		models = append(models, model.Name)
+	}
+	return models, nil
+}
+
+// PullModel downloads a model via the `/api/pull` endpoint and reports the streamed progress.
+func (p *ollamaProvider) PullModel(ctx context.Context, name string, onProgress func(PullProgress)) error {
+	if len(name) == 0 {
+		return fmt.Errorf("model is not valid")
+	}
+	req, err := newJSONRequest(ctx, "POST", p.url("/api/pull"), ollamaPullRequest{Model: name, Stream: true})
+	if err != nil {
+		return err
+	}
+	p.setHeaders(req)
+	resp, err := send(p.endpoint, req, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return readLines(resp.Body, func(line string) (bool, error) {
+		var progress struct {
+			PullProgress
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &progress); err != nil {
+			return false, fmt.Errorf("failed to unmarshal pull progress: %w", err)
+		}
+		if len(progress.Error) > 0 {
+			return false, fmt.Errorf("API error: %s", progress.Error)
+		}
+		if onProgress != nil {
+			onProgress(progress.PullProgress)
+		}
+		return progress.Status == "success", nil
+	})
+}
+
+// newChatRequest creates the HTTP request for the `/api/chat` endpoint.
+// The context window is only sent if the endpoint configures one.
+func (p *ollamaProvider) newChatRequest(ctx context.Context, model string, messages []Message, stream bool) (*http.Request, error) {
+	reqBody := ollamaChatRequest{
+		Model:    model,
+		Messages: messages,
+		Stream:   stream,
+	}
+	if p.endpoint.NumCtx > 0 {
+		reqBody.Options = &ollamaOptions{NumCtx: p.endpoint.NumCtx}
+	}
+	req, err := newJSONRequest(ctx, "POST", p.url("/api/chat"), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	p.setHeaders(req)
+	return req, nil
+}
+
+// url joins the server base URL with the given API path.
+func (p *ollamaProvider) url(path string) string {
+	return strings.TrimSuffix(p.endpoint.URL, "/") + path
+}
+
+// setHeaders sets the authorization header if a token is configured (e.g. behind a proxy).
+func (p *ollamaProvider) setHeaders(req *http.Request) {
+	if len(p.endpoint.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+p.endpoint.Token)
+	}
+}
+
+// usage returns the token usage of the final response, nil if it was not reported.
+func (r ollamaChatResponse) usage() *Usage {
+	if r.PromptEvalCount == 0 && r.EvalCount == 0 {
+		return nil
+	}
+	return &Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+	}
+}
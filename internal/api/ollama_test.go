@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newOllamaServer starts a stand-in for the Ollama API that records the last chat request.
+func newOllamaServer(t *testing.T, last *ollamaChatRequest) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/chat", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(last); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !last.Stream {
+			fmt.Fprint(w, `{"message":{"role":"assistant","content":"Hello"},"done":true,"prompt_eval_count":12,"eval_count":3}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hel"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"lo"},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":12,"eval_count":2}`)
+	})
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"models":[{"name":"llama3:latest"},{"name":"qwen2.5-coder:7b"}]}`)
+	})
+	mux.HandleFunc("POST /api/pull", func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaPullRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+		fmt.Fprintln(w, `{"status":"pulling abc","digest":"sha256:abc","total":100,"completed":50}`)
+		if req.Model == "broken" {
+			fmt.Fprintln(w, `{"error":"max retries exceeded"}`)
+			fmt.Fprintln(w, `{"status":"success"}`)
+			return
+		}
+		fmt.Fprintln(w, `{"status":"pulling abc","digest":"sha256:abc","total":100,"completed":100}`)
+		fmt.Fprintln(w, `{"status":"success"}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaProvider(t *testing.T) {
+	var last ollamaChatRequest
+	server := newOllamaServer(t, &last)
+	provider, err := NewProvider(Endpoint{Provider: PROVIDER_OLLAMA, URL: server.URL + "/", NumCtx: 32768})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	messages := []Message{{Role: "system", Content: "system prompt"}, {Role: "user", Content: "question"}}
+
+	t.Run("SendMessage", func(t *testing.T) {
+		got, err := SendMessage(context.Background(), provider, "llama3", messages)
+		if err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+		if got.Content != "Hello" {
+			t.Errorf("SendMessage() = %q, want %q", got.Content, "Hello")
+		}
+		if want := (Usage{PromptTokens: 12, CompletionTokens: 3}); got.Usage == nil || *got.Usage != want {
+			t.Errorf("SendMessage() usage = %+v, want %+v", got.Usage, want)
+		}
+		if last.Options == nil || last.Options.NumCtx != 32768 {
+			t.Errorf("options = %+v, want num_ctx 32768", last.Options)
+		}
+		if last.Model != "llama3" || !reflect.DeepEqual(last.Messages, messages) {
+			t.Errorf("request = %+v", last)
+		}
+	})
+
+	t.Run("StreamMessage", func(t *testing.T) {
+		chunks := []string{}
+		got, err := StreamMessage(context.Background(), provider, "llama3", messages, func(chunk string) { chunks = append(chunks, chunk) })
+		if err != nil {
+			t.Fatalf("StreamMessage() error = %v", err)
+		}
+		if got.Content != "Hello" || !reflect.DeepEqual(chunks, []string{"Hel", "lo"}) {
+			t.Errorf("StreamMessage() = %q in chunks %q", got.Content, chunks)
+		}
+		if want := (Usage{PromptTokens: 12, CompletionTokens: 2}); got.Usage == nil || *got.Usage != want {
+			t.Errorf("StreamMessage() usage = %+v, want %+v", got.Usage, want)
+		}
+	})
+
+	t.Run("NoNumCtx", func(t *testing.T) {
+		provider, _ := NewProvider(Endpoint{Provider: PROVIDER_OLLAMA, URL: server.URL})
+		last = ollamaChatRequest{}
+		if _, err := SendMessage(context.Background(), provider, "llama3", messages); err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+		if last.Options != nil {
+			t.Errorf("options = %+v, want none", last.Options)
+		}
+	})
+
+	t.Run("GetModels", func(t *testing.T) {
+		got, err := GetModels(context.Background(), provider)
+		if err != nil {
+			t.Fatalf("GetModels() error = %v", err)
+		}
+		if want := []string{"llama3:latest", "qwen2.5-coder:7b"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GetModels() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("PullModel", func(t *testing.T) {
+		puller := provider.(ModelPuller)
+		progress := []PullProgress{}
+		if err := puller.PullModel(context.Background(), "llama3", func(p PullProgress) { progress = append(progress, p) }); err != nil {
+			t.Fatalf("PullModel() error = %v", err)
+		}
+		want := []PullProgress{
+			{Status: "pulling manifest"},
+			{Status: "pulling abc", Digest: "sha256:abc", Total: 100, Completed: 50},
+			{Status: "pulling abc", Digest: "sha256:abc", Total: 100, Completed: 100},
+			{Status: "success"},
+		}
+		if !reflect.DeepEqual(progress, want) {
+			t.Errorf("PullModel() progress = %+v, want %+v", progress, want)
+		}
+	})
+
+	t.Run("PullModelError", func(t *testing.T) {
+		puller := provider.(ModelPuller)
+		progress := 0
+		err := puller.PullModel(context.Background(), "broken", func(PullProgress) { progress++ })
+		if err == nil || !strings.Contains(err.Error(), "max retries exceeded") {
+			t.Errorf("PullModel() error = %v, want the error of the stream", err)
+		}
+		if progress != 2 {
+			t.Errorf("PullModel() reported %d updates, want 2", progress)
+		}
+		if err := puller.PullModel(context.Background(), "", nil); err == nil {
+			t.Errorf("PullModel() without a name succeeded")
+		}
+	})
+}
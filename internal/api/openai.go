@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ChatRequest represents a chat request.
+type ChatRequest struct {
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	Tools         []Tool         `json:"tools,omitempty"`
+}
+
+// StreamOptions represents the options of a streamed chat request.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatUsage represents the token usage block of a chat response.
+type ChatUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+}
+
+// ChatResponse represents a chat response.
+type ChatResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+	Usage *ChatUsage `json:"usage"`
+}
+
+// ChatStreamChunk represents a single chunk of a streamed chat response.
+// The usage is only sent with the last chunk.
+type ChatStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *ChatUsage `json:"usage"`
+}
+
+// ModelsResponse represents a models response.
+type ModelsResponse struct {
+	Data []struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Created int64  `json:"created"`
+		OwnedBy string `json:"owned_by"`
+	} `json:"data"`
+}
+
+// sseDone is the data payload that terminates an OpenAI-compatible event stream.
+const sseDone = "[DONE]"
+
+// openAIProvider implements Provider for OpenAI-compatible `/chat/completions` APIs.
+// The URLs and the authentication are pluggable to support variants like Azure OpenAI.
+type openAIProvider struct {
+	endpoint Endpoint
+	// chatURL returns the chat completions URL for the model.
+	chatURL func(model string) string
+	// modelsURL is the URL of the models listing.
+	modelsURL string
+	// modelsFallback handles a failed models listing (optional).
+	modelsFallback func(err error) ([]string, error)
+	// auth sets the authentication header of a request.
+	auth func(req *http.Request)
+}
+
+// newOpenAIProvider creates a provider for an OpenAI-compatible endpoint.
+// The models URL is derived from the chat completions URL, requests use Bearer authentication.
+func newOpenAIProvider(endpoint Endpoint) *openAIProvider {
+	return &openAIProvider{
+		endpoint:  endpoint,
+		chatURL:   func(string) string { return endpoint.URL },
+		modelsURL: strings.TrimSuffix(endpoint.URL, "/chat/completions") + "/models",
+		auth: func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+endpoint.Token)
+		},
+	}
+}
+
+// SendMessage sends the messages to the chat completions endpoint.
+func (p *openAIProvider) SendMessage(ctx context.Context, model string, messages []Message) (Reply, error) {
+	return p.SendMessageWithTools(ctx, model, messages, nil)
+}
+
+// SendMessageWithTools sends the messages and the tool definitions to the chat completions endpoint.
+func (p *openAIProvider) SendMessageWithTools(ctx context.Context, model string, messages []Message, tools []Tool) (Reply, error) {
+	req, err := p.newChatRequest(ctx, model, messages, tools, false)
+	if err != nil {
+		return Reply{}, err
+	}
+	// Unmarshal the response to a ChatResponse.
+	var chatResp ChatResponse
+	if err := receiveJSON(p.endpoint, req, &chatResp); err != nil {
+		return Reply{}, err
+	}
+	// Check if the response has any choices.
+	if len(chatResp.Choices) == 0 {
+		return Reply{}, fmt.Errorf("no choices in response")
+	}
+	msg := chatResp.Choices[0].Message
+	return Reply{Content: msg.Content, Usage: chatResp.Usage.usage(), ToolCalls: msg.ToolCalls}, nil
+}
+
+// StreamMessage sends the messages to the chat completions endpoint with streaming enabled.
+func (p *openAIProvider) StreamMessage(ctx context.Context, model string, messages []Message, onChunk func(string)) (Reply, error) {
+	req, err := p.newChatRequest(ctx, model, messages, nil, true)
+	if err != nil {
+		return Reply{}, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := send(p.endpoint, req, true)
+	if err != nil {
+		return Reply{}, err
+	}
+	defer resp.Body.Close()
+	return readChatStream(resp.Body, onChunk)
+}
+
+// GetModels lists the models of the models endpoint.
+func (p *openAIProvider) GetModels(ctx context.Context) ([]string, error) {
+	req, err := newJSONRequest(ctx, "GET", p.modelsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Set the authorization header.
+	p.auth(req)
+	// Unmarshal the response to a ModelsResponse.
+	var modelsResp ModelsResponse
+	if err := receiveJSON(p.endpoint, req, &modelsResp); err != nil {
+		if p.modelsFallback != nil {
+			return p.modelsFallback(err)
+		}
+		return nil, err
+	}
+	// Create a new list of models.
+	models := []string{}
+	for _, model := range modelsResp.Data {
+		models = append(models, model.ID)
+	}
+	return models, nil
+}
+
+// newChatRequest creates the HTTP request for a chat completion.
+func (p *openAIProvider) newChatRequest(ctx context.Context, model string, messages []Message, tools []Tool, stream bool) (*http.Request, error) {
+	// Create a new chat request.
+	reqBody := ChatRequest{
+		Model:    model,
+		Messages: messages,
+		Stream:   stream,
+		Tools:    tools,
+	}
+	// Ask for the token usage at the end of the stream
+	if stream {
+		reqBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	req, err := newJSONRequest(ctx, "POST", p.chatURL(model), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	// Set the authorization header.
+	p.auth(req)
+	return req, nil
+}
+
+// readChatStream reads an OpenAI-compatible `text/event-stream` body.
+// It decodes every `data:` event into a ChatStreamChunk, forwards the delta content to onChunk
+// and returns the assembled reply. The stream ends on `[DONE]` or EOF.
+func readChatStream(r io.Reader, onChunk func(string)) (Reply, error) {
+	var content strings.Builder
+	var usage *Usage
+	err := readEvents(r, func(data string) (bool, error) {
+		if data == sseDone {
+			return true, nil
+		}
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.usage()
+		}
+		for _, choice := range chunk.Choices {
+			if len(choice.Delta.Content) == 0 {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			if onChunk != nil {
+				onChunk(choice.Delta.Content)
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return Reply{Content: content.String()}, err
+	}
+	if content.Len() == 0 {
+		return Reply{}, fmt.Errorf("no content in response stream")
+	}
+	return Reply{Content: content.String(), Usage: usage}, nil
+}
+
+// usage converts the usage block to a Usage, nil if the block is missing.
+func (u *ChatUsage) usage() *Usage {
+	if u == nil {
+		return nil
+	}
+	return &Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		CachedTokens:     u.PromptTokensDetails.CachedTokens,
+	}
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/thxrsxm/harzmind-code/internal/logger"
+)
+
+// RetryPolicy configures how failed API requests are retried.
+// Only failures that are safe to repeat are retried: 429, 5xx (except 501) and connections dropped before the response.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one (1 disables retries).
+	MaxAttempts int `json:"maxAttempts"`
+	// BaseDelayMs is the delay before the first retry in milliseconds, doubled for every further retry.
+	BaseDelayMs int `json:"baseDelayMs"`
+	// MaxDelayMs caps the delay between two attempts in milliseconds.
+	// If the server asks to wait longer, the request is not retried.
+	MaxDelayMs int `json:"maxDelayMs"`
+	// Jitter randomizes each delay by up to this fraction (0.2 means ±20%).
+	Jitter float64 `json:"jitter"`
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelayMs: 1000,
+		MaxDelayMs:  30000,
+		Jitter:      0.2,
+	}
+}
+
+// RetryHook is called before an API request is retried.
+type RetryHook func(attempt, maxAttempts int, wait time.Duration, err error)
+
+// retryHookKey is the context key of the RetryHook.
+type retryHookKey struct{}
+
+// WithRetryHook returns a copy of ctx that calls hook before every retry of a request made with it.
+func WithRetryHook(ctx context.Context, hook RetryHook) context.Context {
+	return context.WithValue(ctx, retryHookKey{}, hook)
+}
+
+// doWithRetry sends the request with the client and retries it according to the policy.
+// It returns the first response that is not retryable (or the last one when all attempts are used).
+func doWithRetry(client *http.Client, req *http.Request, policy RetryPolicy) (*http.Response, error) {
+	ctx := req.Context()
+	maxAttempts := max(policy.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		resp, err := client.Do(req)
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// Keep API keys in the URL out of errors and logs
+		maskURLError(err, req.URL)
+		// Check whether the failure is retryable
+		wait, retry := policy.retryDelay(attempt, resp, err)
+		if !retry || attempt >= maxAttempts {
+			if err != nil {
+				return nil, fmt.Errorf("request failed: %w", err)
+			}
+			return resp, nil
+		}
+		reason := err
+		if resp != nil {
+			reason = fmt.Errorf("API error: %s", resp.Status)
+			// Drain and close the body to reuse the connection
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		logger.Log(logger.WARNING, "attempt %d/%d for %s failed: %v, retrying in %s", attempt, maxAttempts, redactURL(req.URL), reason, wait.Round(time.Millisecond))
+		if hook, ok := ctx.Value(retryHookKey{}).(RetryHook); ok {
+			hook(attempt+1, maxAttempts, wait, reason)
+		}
+		// Wait before the next attempt
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		// Rewind the request body
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryDelay reports whether the result of an attempt should be retried and how long to wait before.
+// Delays requested by the server (see serverDelay) take precedence over the exponential backoff.
+func (p RetryPolicy) retryDelay(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if err != nil {
+		if !isConnectionReset(err) {
+			return 0, false
+		}
+		return p.backoff(attempt), true
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && (resp.StatusCode < 500 || resp.StatusCode == http.StatusNotImplemented) {
+		return 0, false
+	}
+	wait, ok := serverDelay(resp.Header, time.Now())
+	if !ok {
+		return p.backoff(attempt), true
+	}
+	// Give up if the server asks to wait longer than allowed
+	if p.MaxDelayMs > 0 && wait > time.Duration(p.MaxDelayMs)*time.Millisecond {
+		return 0, false
+	}
+	return wait, true
+}
+
+// backoff returns the exponential backoff delay with jitter for the given attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := time.Duration(p.BaseDelayMs) * time.Millisecond << (attempt - 1)
+	if maxDelay := time.Duration(p.MaxDelayMs) * time.Millisecond; maxDelay > 0 && (wait > maxDelay || wait <= 0) {
+		wait = maxDelay
+	}
+	if p.Jitter > 0 {
+		wait += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(wait))
+	}
+	return max(wait, 0)
+}
+
+// serverDelay returns the delay requested by the rate limit headers of a response.
+// `Retry-After` (seconds or HTTP date) takes precedence. Without it, the `x-ratelimit-reset-*` headers
+// (durations like `6m0s`, seconds, or RFC 3339 timestamps) are used for the limits that are exhausted,
+// i.e. whose `x-ratelimit-remaining-*` header is 0, so that the reset of an unrelated limit is not waited for.
+func serverDelay(header http.Header, now time.Time) (time.Duration, bool) {
+	if value := header.Get("Retry-After"); len(value) > 0 {
+		if d, ok := parseDelay(strings.TrimSpace(value), now); ok {
+			return d, true
+		}
+	}
+	var wait time.Duration
+	found := false
+	for key, values := range header {
+		limit, ok := strings.CutPrefix(strings.ToLower(key), "x-ratelimit-reset")
+		if !ok || strings.TrimSpace(header.Get("X-Ratelimit-Remaining"+limit)) != "0" {
+			continue
+		}
+		for _, value := range values {
+			if d, ok := parseDelay(strings.TrimSpace(value), now); ok {
+				wait = max(wait, d)
+				found = true
+			}
+		}
+	}
+	return wait, found
+}
+
+// parseDelay parses a delay given as duration, seconds, HTTP date or RFC 3339 timestamp.
+func parseDelay(value string, now time.Time) (time.Duration, bool) {
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return max(time.Duration(seconds*float64(time.Second)), 0), true
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return max(d, 0), true
+	}
+	for _, layout := range []string{http.TimeFormat, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return max(t.Sub(now), 0), true
+		}
+	}
+	return 0, false
+}
+
+// isConnectionReset reports whether the error of a request was caused by the server dropping the connection:
+// a reset, or a connection closed before the response headers arrived.
+// Only errors of sending a request are checked, errors while reading a response (e.g. a stream) are never retried.
+func isConnectionReset(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && (urlErr.Err == io.EOF || urlErr.Err == io.ErrUnexpectedEOF)
+}
+
+// secretParams are the query parameters that carry API keys (e.g. `key` of Gemini).
+var secretParams []string = []string{"key", "api-key", "api_key", "access_token"}
+
+// redactURL returns the URL with the password and the values of the secret query parameters masked.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	q := redacted.Query()
+	for _, name := range secretParams {
+		if q.Has(name) {
+			q.Set(name, "xxxxx")
+			redacted.RawQuery = q.Encode()
+		}
+	}
+	return redacted.Redacted()
+}
+
+// maskURLError masks the secrets of the URL in a transport error, which contains the full URL.
+func maskURLError(err error, u *url.URL) {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactURL(u)
+	}
+}
+
+// rewind returns a copy of the request with a fresh body for another attempt.
+func rewind(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// readEvents parses server-sent events and calls handle with the data of every event.
+// Multi-line data fields are joined with newlines, comments and other fields are ignored.
+// Reading stops when handle returns true, an error, or the reader is exhausted.
+func readEvents(r io.Reader, handle func(data string) (bool, error)) error {
+	scanner := bufio.NewScanner(r)
+	// Allow large events (e.g. long code blocks in a single chunk)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	var data []string
+	dispatch := func() (bool, error) {
+		if len(data) == 0 {
+			return false, nil
+		}
+		payload := strings.Join(data, "\n")
+		data = data[:0]
+		return handle(payload)
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		// An empty line terminates the current event
+		if len(line) == 0 {
+			done, err := dispatch()
+			if err != nil || done {
+				return err
+			}
+			continue
+		}
+		// Collect data fields, skip comments and other fields
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read response stream: %w", err)
+	}
+	// Dispatch a trailing event without a terminating empty line
+	_, err := dispatch()
+	return err
+}
+
+// readLines reads a newline-delimited JSON (NDJSON) stream and calls handle for every non-empty line.
+// Reading stops when handle returns true, an error, or the reader is exhausted.
+func readLines(r io.Reader, handle func(line string) (bool, error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		done, err := handle(line)
+		if err != nil || done {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read response stream: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadChatStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{"Single chunk", "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\ndata: [DONE]\n\n", "Hello", false},
+		{"Multiple chunks", "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\ndata: [DONE]\n\n", "Hello", false},
+		{"Comments are ignored", ": keep-alive\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n", "Hi", false},
+		{"Stops at DONE", "data: {\"choices\":[{\"delta\":{\"content\":\"A\"}}]}\n\ndata: [DONE]\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"B\"}}]}\n\n", "A", false},
+		{"Invalid JSON", "data: {invalid\n\n", "", true},
+		{"Empty stream", "data: [DONE]\n\n", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chunks []string
+			got, err := readChatStream(strings.NewReader(tt.body), func(chunk string) {
+				chunks = append(chunks, chunk)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readChatStream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Content != tt.want {
+				t.Errorf("readChatStream() = %q, want %q", got.Content, tt.want)
+			}
+			if strings.Join(chunks, "") != tt.want {
+				t.Errorf("chunks = %q, want %q", chunks, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIStreamMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, part := range []string{"Hello", ", ", "world"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", part)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":10,\"completion_tokens\":3,\"prompt_tokens_details\":{\"cached_tokens\":4}}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(Endpoint{URL: server.URL, Token: "token"})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	got, err := StreamMessage(context.Background(), provider, "model", []Message{{Role: "user", Content: "Hi"}}, nil)
+	if err != nil {
+		t.Fatalf("StreamMessage() error = %v", err)
+	}
+	if got.Content != "Hello, world" {
+		t.Errorf("StreamMessage() = %q, want %q", got.Content, "Hello, world")
+	}
+	if want := (Usage{PromptTokens: 10, CompletionTokens: 3, CachedTokens: 4}); got.Usage == nil || *got.Usage != want {
+		t.Errorf("StreamMessage() usage = %+v, want %+v", got.Usage, want)
+	}
+	provider, _ = NewProvider(Endpoint{URL: server.URL, Token: "wrong"})
+	if _, err := StreamMessage(context.Background(), provider, "model", nil, nil); err == nil {
+		t.Errorf("StreamMessage() expected error for unauthorized request")
+	}
+}
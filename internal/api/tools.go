@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Tool describes a function the model can call (OpenAI `tools` format).
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction describes the name, purpose and JSON schema of the parameters of a tool.
+type ToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+// ToolCall is a call of a tool requested by the model.
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall contains the name of the called tool and its arguments as JSON object.
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// DecodeArguments unmarshals the JSON arguments of the call into v.
+func (c ToolCall) DecodeArguments(v any) error {
+	if len(c.Function.Arguments) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(c.Function.Arguments), v); err != nil {
+		return fmt.Errorf("invalid arguments of %s: %w", c.Function.Name, err)
+	}
+	return nil
+}
+
+// ToolCaller is implemented by providers that support tool calling.
+type ToolCaller interface {
+	// SendMessageWithTools sends the messages with the tool definitions and returns the reply.
+	// The reply either contains tool calls or the final answer.
+	SendMessageWithTools(ctx context.Context, model string, messages []Message, tools []Tool) (Reply, error)
+}
+
+// SendMessageWithTools sends the messages with the tool definitions through a provider that supports tool calling.
+func SendMessageWithTools(ctx context.Context, provider Provider, model string, messages []Message, tools []Tool) (Reply, error) {
+	// Check if the model is valid.
+	if len(model) == 0 {
+		return Reply{}, fmt.Errorf("model is not valid")
+	}
+	caller, ok := provider.(ToolCaller)
+	if !ok {
+		return Reply{}, fmt.Errorf("provider does not support tool calling")
+	}
+	return caller.SendMessageWithTools(ctx, model, messages, tools)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendMessageWithTools(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Tools) != 1 || req.Tools[0].Function.Name != "read_file" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"","tool_calls":[`+
+			`{"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"main.go\"}"}}]}}]}`)
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(Endpoint{URL: server.URL, Token: "key"})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	tools := []Tool{{Type: "function", Function: ToolFunction{Name: "read_file", Parameters: map[string]any{"type": "object"}}}}
+	got, err := SendMessageWithTools(context.Background(), provider, "gpt-4o", []Message{{Role: "user", Content: "Hi"}}, tools)
+	if err != nil {
+		t.Fatalf("SendMessageWithTools() error = %v", err)
+	}
+	if len(got.ToolCalls) != 1 || got.ToolCalls[0].ID != "call_1" {
+		t.Fatalf("SendMessageWithTools() tool calls = %+v", got.ToolCalls)
+	}
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := got.ToolCalls[0].DecodeArguments(&args); err != nil || args.Path != "main.go" {
+		t.Errorf("DecodeArguments() = %+v, %v", args, err)
+	}
+}
+
+func TestSendMessageWithToolsUnsupported(t *testing.T) {
+	provider, err := NewProvider(Endpoint{Provider: PROVIDER_ANTHROPIC, URL: "http://localhost", Token: "key"})
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	if _, err := SendMessageWithTools(context.Background(), provider, "claude-sonnet-4-5", nil, nil); err == nil {
+		t.Error("SendMessageWithTools() error = nil, want error for provider without tool calling")
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// transportKey are the settings of an endpoint that determine its transport.
+type transportKey struct {
+	timeout            time.Duration
+	proxy              string
+	caCert             string
+	insecureSkipVerify bool
+}
+
+// transports are the transports created so far, shared by all requests with the same settings,
+// so that their connections are reused.
+var transports = struct {
+	mu sync.Mutex
+	m  map[transportKey]*http.Transport
+}{m: map[transportKey]*http.Transport{}}
+
+// newClient returns an HTTP client for requests to the endpoint.
+// It applies the proxy, custom CA bundle and TLS verification settings of the endpoint.
+// Streaming clients only limit the time until the response headers arrive.
+func newClient(endpoint Endpoint, stream bool) (*http.Client, error) {
+	timeout := endpoint.Timeout
+	if timeout <= 0 {
+		timeout = requestTimeout
+	}
+	transport, err := sharedTransport(transportKey{
+		timeout:            timeout,
+		proxy:              endpoint.Proxy,
+		caCert:             endpoint.CACert,
+		insecureSkipVerify: endpoint.InsecureSkipVerify,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: transport}
+	if !stream {
+		client.Timeout = timeout
+	}
+	return client, nil
+}
+
+// sharedTransport returns the transport for the settings, creating it on first use.
+func sharedTransport(key transportKey) (*http.Transport, error) {
+	transports.mu.Lock()
+	defer transports.mu.Unlock()
+	if transport, ok := transports.m[key]; ok {
+		return transport, nil
+	}
+	transport, err := newTransport(key)
+	if err != nil {
+		return nil, err
+	}
+	transports.m[key] = transport
+	return transport, nil
+}
+
+// newTransport creates a transport with the settings.
+func newTransport(key transportKey) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = key.timeout
+	// Use the configured proxy instead of the environment
+	if len(key.proxy) > 0 {
+		proxyURL, err := url.Parse(key.proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	// Configure TLS for intercepting proxies and local gateways
+	if len(key.caCert) > 0 || key.insecureSkipVerify {
+		tlsConfig := &tls.Config{InsecureSkipVerify: key.insecureSkipVerify}
+		if len(key.caCert) > 0 {
+			pool, err := loadCertPool(key.caCert)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return transport, nil
+}
+
+// loadCertPool returns the system certificate pool extended by the PEM certificates of the file.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewClientReusesTransport(t *testing.T) {
+	endpoint := Endpoint{URL: "https://api.example.com", Timeout: 42 * time.Second}
+	first, err := newClient(endpoint, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := newClient(endpoint, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Transport != stream.Transport {
+		t.Errorf("newClient() created a new transport for the same settings")
+	}
+	if first.Timeout != 42*time.Second || stream.Timeout != 0 {
+		t.Errorf("timeouts = %v, %v, want 42s and none for streaming", first.Timeout, stream.Timeout)
+	}
+	endpoint.Proxy = "http://proxy.example.com:8080"
+	proxied, err := newClient(endpoint, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxied.Transport == first.Transport {
+		t.Errorf("newClient() reused the transport for other settings")
+	}
+	endpoint.CACert = "missing.pem"
+	if _, err := newClient(endpoint, false); err == nil {
+		t.Errorf("newClient() with a missing CA bundle succeeded")
+	}
+}
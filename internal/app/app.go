@@ -6,22 +6,33 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/thxrsxm/harzmind-code/internal"
+	"github.com/thxrsxm/harzmind-code/internal/acc"
+	"github.com/thxrsxm/harzmind-code/internal/agent"
 	"github.com/thxrsxm/harzmind-code/internal/api"
 	"github.com/thxrsxm/harzmind-code/internal/args"
 	"github.com/thxrsxm/harzmind-code/internal/codebase"
 	"github.com/thxrsxm/harzmind-code/internal/common"
+	"github.com/thxrsxm/harzmind-code/internal/config"
+	"github.com/thxrsxm/harzmind-code/internal/edits"
 	"github.com/thxrsxm/harzmind-code/internal/executor"
 	"github.com/thxrsxm/harzmind-code/internal/input"
 	"github.com/thxrsxm/harzmind-code/internal/llmx"
 	"github.com/thxrsxm/harzmind-code/internal/logger"
 	"github.com/thxrsxm/harzmind-code/internal/output"
+	"github.com/thxrsxm/harzmind-code/internal/policy"
 	"github.com/thxrsxm/harzmind-code/internal/repl"
+	"github.com/thxrsxm/harzmind-code/internal/session"
 	"github.com/thxrsxm/harzmind-code/internal/setup"
+	"github.com/thxrsxm/harzmind-code/internal/snapshot"
 	"github.com/thxrsxm/rnbw"
 )
 
@@ -112,22 +123,52 @@ func Run() {
 		fmt.Fprintf(os.Stdout, "v%s\n", internal.VERSION_DATE)
 		os.Exit(0)
 	}
+	// Create the command policy from the global and the project policy
+	projectPolicy, err := policy.LoadFile(common.PATH_FILE_POLICY)
+	if err != nil {
+		output.PrintfWarning("ignoring project command policy: %v\n", err)
+		logger.Log(logger.ERROR, "%v", err)
+	}
+	commands := policy.NewProjectChecker(config.GetCommandPolicy(), projectPolicy)
+	// Commands must not see the configuration with the API keys
+	sandbox := config.GetSandbox().Options(common.PATH_DIR_BINARY_DATA)
+	bashSandbox := config.GetSandbox().BashOptions(common.PATH_DIR_BINARY_DATA)
+	codebase.SetLimits(config.GetCodebaseLimits())
 	// Create new LLM client
-	llmClient := llmx.NewLLMx()
+	llmClient := llmx.NewLLMx(config.GetCompactionPolicy(), config.GetAgentPolicy(), agent.Env{Commands: commands, Exec: sandbox})
+	checkAgentMode(config, llmClient)
+	// The saved session of the conversation, created with the first answered message
+	var current *session.Session
+	// saveCurrent saves the conversation in the current session, creating it if necessary
+	saveCurrent := func() error {
+		if current == nil {
+			current = session.New()
+		}
+		return saveSession(current, config, llmClient)
+	}
 	// Create new REPL
-	r, err := repl.NewREPL(func(input string) error {
-		// Get current account
-		account, err := config.GetAccountManager().GetCurrentAccount()
+	r, err := repl.NewREPL(func(ctx context.Context, input string) error {
+		// Create the target of the current account
+		target, err := newTarget(config, "")
 		if err != nil {
 			return err
 		}
-		// Handle user message
-		resp, err := llmClient.HandleUserMessage(input, account.ApiUrl, account.Model, account.ApiKey)
+		// Handle user message and print the response while it is streamed
+		var reply string
+		err = streamResponse(func(onChunk func(string)) error {
+			reply, err = llmClient.HandleUserMessage(ctx, input, target, onChunk)
+			return err
+		})
 		if err != nil {
 			return err
 		}
-		output.Printf("\n%s\n", resp)
-		return nil
+		// Save the conversation
+		if err := saveCurrent(); err != nil {
+			return err
+		}
+		// Apply the proposed file edits
+		_, err = edits.Review(reply)
+		return err
 	})
 	if err != nil {
 		rnbw.ForegroundColor(rnbw.Red)
@@ -146,7 +187,7 @@ func Run() {
 	r.AddCommand(repl.NewCMD(
 		"brocken",
 		"Shows the Brocken",
-		func(arg string) error {
+		func(ctx context.Context, arg string) error {
 			common.PrintBrocken()
 			return nil
 		},
@@ -155,12 +196,33 @@ func Run() {
 	r.AddCommand(repl.NewCMD(
 		"tree",
 		"Codebase tree visualization",
-		func(arg string) error {
-			files, err := codebase.GetCodeBase(".")
+		func(ctx context.Context, arg string) error {
+			files, skipped, err := codebase.Scan(".")
 			if err != nil {
 				return err
 			}
 			output.Print(codebase.Tree(files))
+			printSkipped(skipped)
+			return nil
+		},
+	))
+	// /ignored — explain whether a path is ignored and by which rule
+	r.AddCommand(repl.NewCMD(
+		"ignored",
+		"Explain why a path is ignored",
+		func(ctx context.Context, arg string) error {
+			if len(arg) == 0 {
+				return fmt.Errorf("wrong format")
+			}
+			ignored, rule := codebase.ExplainIgnored(arg)
+			switch {
+			case ignored:
+				output.Printf("%s is ignored by %s:%d: %s\n", arg, rule.Source, rule.Line, rule.Pattern)
+			case rule != nil:
+				output.Printf("%s is not ignored, it is re-included by %s:%d: %s\n", arg, rule.Source, rule.Line, rule.Pattern)
+			default:
+				output.Printf("%s is not ignored\n", arg)
+			}
 			return nil
 		},
 	))
@@ -168,7 +230,7 @@ func Run() {
 	r.AddCommand(repl.NewCMD(
 		"info",
 		"Show info",
-		func(arg string) error {
+		func(ctx context.Context, arg string) error {
 			rnbw.ForegroundColor(rnbw.Green)
 			output.Print("HarzMind Code")
 			rnbw.ResetColor()
@@ -181,17 +243,21 @@ func Run() {
 	r.AddCommand(repl.NewCMD(
 		"session",
 		"Shows current session info",
-		func(arg string) error {
+		func(ctx context.Context, arg string) error {
 			accountName := "-"
 			model := "-"
+			window := "-"
 			// Get current account
 			account, err := config.GetAccountManager().GetCurrentAccount()
 			if err == nil {
 				accountName = account.Name
-				model = account.Model
+				model = account.GetModel()
 				if len(model) == 0 {
 					model = "-"
 				}
+				if n := account.GetContextWindow(); n > 0 {
+					window = fmt.Sprint(n)
+				}
 			}
 			// Get current working directory
 			dir, err := os.Getwd()
@@ -203,23 +269,55 @@ func Run() {
 			output.Printf("Account:	'%s'\n", accountName)
 			output.Printf("Model:		'%s'\n", model)
 			output.Printf("Directory:	'%s'\n", dir)
-			output.Printf("Context:	%d tokens\n", llmClient.GetTokens())
+			output.Printf("Context:	%d / %s tokens\n", llmClient.GetTokens(), window)
+			output.Printf("Agent mode:	%t\n", llmClient.AgentMode())
+			if _, skipped, err := codebase.Scan("."); err == nil {
+				output.Printf("Skipped:	%s\n", codebase.SkippedSummary(skipped))
+			} else {
+				logger.Log(logger.ERROR, "%v", err)
+			}
 			return nil
 		},
 	))
-	// /bash — execute shell commands via os/exec
+	// /cost — show token usage and cost per turn and for the session
+	r.AddCommand(repl.NewCMD(
+		"cost",
+		"Show token usage and cost",
+		func(ctx context.Context, arg string) error {
+			turns := llmClient.GetTurns()
+			if len(turns) == 0 {
+				output.Println("No turns yet")
+				return nil
+			}
+			output.Print(llmx.CostReport(turns, config.GetPrices(), config.GetCurrency()))
+			return nil
+		},
+	))
+	// /bash — execute shell commands via os/exec if the command policy allows it
 	r.AddCommand(repl.NewCMD(
 		"bash",
 		"Run bash",
-		func(arg string) error {
-			out, err := executor.ExecuteBash(arg)
-			if err != nil {
-				rnbw.ForegroundColor(rnbw.Red)
+		func(ctx context.Context, arg string) error {
+			_, err := runBash(ctx, arg, commands, bashSandbox)
+			return err
+		},
+	))
+	// /bash! — execute a shell command and attach its output to the next message
+	r.AddCommand(repl.NewCMD(
+		"bash!",
+		"Run bash and attach the output to the next message",
+		func(ctx context.Context, arg string) error {
+			if len(arg) == 0 {
+				return fmt.Errorf("wrong format")
 			}
-			output.Print(out)
-			if len(out) >= 1 && out[len(out)-1] != '\n' {
-				output.Println()
+			res, err := runBash(ctx, arg, commands, bashSandbox)
+			if err != nil {
+				return err
 			}
+			llmClient.Attach(fmt.Sprintf("Output of `%s` (%s)", arg, strings.ReplaceAll(res.Report(), "\n", ", ")), res.Output)
+			rnbw.ForegroundColor(rnbw.Green)
+			output.Println("Attached the output to the next message")
+			rnbw.ResetColor()
 			return nil
 		},
 	))
@@ -227,7 +325,7 @@ func Run() {
 	r.AddCommand(repl.NewCMD(
 		"editor",
 		"Open CLI editor",
-		func(arg string) error {
+		func(ctx context.Context, arg string) error {
 			if len(arg) == 0 {
 				return fmt.Errorf("wrong format")
 			}
@@ -244,8 +342,13 @@ func Run() {
 	r.AddCommand(repl.NewCMD(
 		"clear",
 		"Clear session context",
-		func(arg string) error {
+		func(ctx context.Context, arg string) error {
 			llmClient.ClearMessages()
+			if current != nil {
+				if err := saveSession(current, config, llmClient); err != nil {
+					return err
+				}
+			}
 			rnbw.ForegroundColor(rnbw.Green)
 			output.Println("Context was successfully deleted")
 			rnbw.ResetColor()
@@ -253,19 +356,339 @@ func Run() {
 			return nil
 		},
 	))
+	// /compact — summarize older turns of the conversation
+	r.AddCommand(repl.NewCMD(
+		"compact",
+		"Summarize older turns",
+		func(ctx context.Context, arg string) error {
+			target, err := newTarget(config, "")
+			if err != nil {
+				return err
+			}
+			if err := llmClient.Compact(ctx, target); err != nil {
+				return err
+			}
+			if current != nil {
+				return saveSession(current, config, llmClient)
+			}
+			return nil
+		},
+	))
+	// /agent [on|off] — switch agent mode (tool calls instead of the full codebase)
+	r.AddCommand(repl.NewCMD(
+		"agent",
+		"Switch agent mode",
+		func(ctx context.Context, arg string) error {
+			switch strings.ToLower(arg) {
+			case "":
+			case "on":
+				if err := supportsTools(config); err != nil {
+					return err
+				}
+				llmClient.SetAgentMode(true)
+				logger.Log(logger.INFO, "%s", "enabled agent mode")
+			case "off":
+				llmClient.SetAgentMode(false)
+				logger.Log(logger.INFO, "%s", "disabled agent mode")
+			default:
+				return fmt.Errorf("wrong format")
+			}
+			if llmClient.AgentMode() {
+				output.Println("Agent mode is on")
+			} else {
+				output.Println("Agent mode is off")
+			}
+			return nil
+		},
+	))
+	// /undo — remove the last turn
+	r.AddCommand(repl.NewCMD(
+		"undo",
+		"Remove last message and answer",
+		func(ctx context.Context, arg string) error {
+			if err := llmClient.Undo(); err != nil {
+				return err
+			}
+			rnbw.ForegroundColor(rnbw.Green)
+			output.Println("Removed the last message and its answer")
+			rnbw.ResetColor()
+			logger.Log(logger.INFO, "%s", "undid last turn")
+			if current != nil {
+				return saveSession(current, config, llmClient)
+			}
+			return nil
+		},
+	))
+	// /retry [model] — resend the last message, optionally to another model
+	r.AddCommand(repl.NewCMD(
+		"retry",
+		"Resend last message",
+		func(ctx context.Context, arg string) error {
+			msg, ok := llmClient.LastUserMessage()
+			if !ok {
+				return fmt.Errorf("no message to retry")
+			}
+			target, err := newTarget(config, arg)
+			if err != nil {
+				return err
+			}
+			logger.Log(logger.INFO, "retrying last message with model '%s'", target.Model)
+			var reply string
+			err = streamResponse(func(onChunk func(string)) error {
+				reply, err = llmClient.Resend(ctx, msg, target, onChunk)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if err := saveCurrent(); err != nil {
+				return err
+			}
+			_, err = edits.Review(reply)
+			return err
+		},
+	))
+	// /edit — edit the last message in $EDITOR and resend it
+	r.AddCommand(repl.NewCMD(
+		"edit",
+		"Edit and resend last message",
+		func(ctx context.Context, arg string) error {
+			msg, ok := llmClient.LastUserMessage()
+			if !ok {
+				return fmt.Errorf("no message to edit")
+			}
+			edited, err := executor.EditText(msg)
+			if err != nil {
+				return err
+			}
+			edited = strings.TrimSpace(edited)
+			if len(edited) == 0 {
+				return fmt.Errorf("message is empty")
+			}
+			// Show the edited message, so that it is part of the transcript
+			output.SetWriteMode(output.FILE)
+			output.Println(edited)
+			output.SetWriteMode(output.ALL)
+			target, err := newTarget(config, "")
+			if err != nil {
+				return err
+			}
+			var reply string
+			err = streamResponse(func(onChunk func(string)) error {
+				reply, err = llmClient.Resend(ctx, edited, target, onChunk)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if err := saveCurrent(); err != nil {
+				return err
+			}
+			_, err = edits.Review(reply)
+			return err
+		},
+	))
+	// /branch [name] — list branches or fork the conversation into a new branch
+	r.AddCommand(repl.NewCMD(
+		"branch",
+		"List or create branches",
+		func(ctx context.Context, arg string) error {
+			if len(arg) == 0 {
+				for _, name := range llmClient.BranchNames() {
+					if name == llmClient.GetBranch() {
+						output.Printf("* %s\n", name)
+					} else {
+						output.Printf("  %s\n", name)
+					}
+				}
+				return nil
+			}
+			if err := llmClient.CreateBranch(arg); err != nil {
+				return err
+			}
+			rnbw.ForegroundColor(rnbw.Green)
+			output.Printf("Switched to new branch '%s'\n", arg)
+			rnbw.ResetColor()
+			logger.Log(logger.INFO, "created branch '%s'", arg)
+			if current != nil {
+				return saveSession(current, config, llmClient)
+			}
+			return nil
+		},
+	))
+	// /checkout <name> — switch to another branch of the conversation
+	r.AddCommand(repl.NewCMD(
+		"checkout",
+		"Switch branch",
+		func(ctx context.Context, arg string) error {
+			if len(arg) == 0 {
+				return fmt.Errorf("wrong format")
+			}
+			if err := llmClient.Checkout(arg); err != nil {
+				return err
+			}
+			rnbw.ForegroundColor(rnbw.Green)
+			output.Printf("Switched to branch '%s'\n", arg)
+			rnbw.ResetColor()
+			logger.Log(logger.INFO, "checked out branch '%s'", arg)
+			if current != nil {
+				return saveSession(current, config, llmClient)
+			}
+			return nil
+		},
+	))
+	// /changes [turn] — list files changed since a turn (default: the last one)
+	r.AddCommand(repl.NewCMD(
+		"changes",
+		"List files changed since a turn",
+		func(ctx context.Context, arg string) error {
+			store := snapshot.NewStore(common.PATH_DIR_SNAPSHOTS)
+			m, changes, err := changesSince(store, arg)
+			if err != nil {
+				return err
+			}
+			output.Printf("Changes since turn %d (%s):\n", m.Turn, m.Time.Format("2006-01-02 15:04:05"))
+			printChanges(changes)
+			return nil
+		},
+	))
+	// /rollback <turn> — restore the files changed since a turn
+	r.AddCommand(repl.NewCMD(
+		"rollback",
+		"Restore files of a turn",
+		func(ctx context.Context, arg string) error {
+			if len(arg) == 0 {
+				return fmt.Errorf("wrong format")
+			}
+			store := snapshot.NewStore(common.PATH_DIR_SNAPSHOTS)
+			m, changes, err := changesSince(store, arg)
+			if err != nil {
+				return err
+			}
+			if len(changes) == 0 {
+				return nil
+			}
+			printChanges(changes)
+			// Ask for confirmation
+			output.Printf("Restore %d files to turn %d? [y/n] ", len(changes), m.Turn)
+			answer, err := input.ReadInput(true)
+			if err != nil {
+				return err
+			}
+			if answer = strings.ToLower(answer); answer != "y" && answer != "yes" {
+				output.Println("Rollback cancelled")
+				return nil
+			}
+			if err := store.Restore(m, changes); err != nil {
+				return err
+			}
+			rnbw.ForegroundColor(rnbw.Green)
+			output.Printf("Successfully restored %d files to turn %d\n", len(changes), m.Turn)
+			rnbw.ResetColor()
+			logger.Log(logger.INFO, "rolled back %d files to turn %d", len(changes), m.Turn)
+			return nil
+		},
+	))
+	// /sessions — list saved sessions
+	r.AddCommand(repl.NewCMD(
+		"sessions",
+		"List saved sessions",
+		func(ctx context.Context, arg string) error {
+			sessions, err := session.List(common.PATH_DIR_SESSIONS)
+			if err != nil {
+				return err
+			}
+			if len(sessions) == 0 {
+				output.Println("no sessions")
+				return nil
+			}
+			for _, s := range sessions {
+				marker := " "
+				if current != nil && current.ID == s.ID {
+					marker = "*"
+				}
+				output.Printf("%s %s  %s  %-20s  %3d turns  %s\n", marker, s.ID, s.Updated.Format("2006-01-02 15:04"), s.Model, len(s.Turns), s.Title)
+			}
+			return nil
+		},
+	))
+	// /resume — continue a saved session
+	r.AddCommand(repl.NewCMD(
+		"resume",
+		"Resume a session",
+		func(ctx context.Context, arg string) error {
+			if len(arg) == 0 {
+				return fmt.Errorf("wrong format")
+			}
+			s, err := session.Load(common.PATH_DIR_SESSIONS, arg)
+			if err != nil {
+				return err
+			}
+			current = s
+			resumeSession(s, config, llmClient)
+			return nil
+		},
+	))
+	// /rename — change the title of the current session
+	r.AddCommand(repl.NewCMD(
+		"rename",
+		"Rename current session",
+		func(ctx context.Context, arg string) error {
+			if len(arg) == 0 {
+				return fmt.Errorf("wrong format")
+			}
+			if current == nil {
+				return fmt.Errorf("no session")
+			}
+			current.Title = arg
+			if err := current.Save(common.PATH_DIR_SESSIONS); err != nil {
+				return err
+			}
+			rnbw.ForegroundColor(rnbw.Green)
+			output.Printf("Successfully renamed session %s to '%s'\n", current.ID, arg)
+			rnbw.ResetColor()
+			return nil
+		},
+	))
+	// /delete — delete a saved session
+	r.AddCommand(repl.NewCMD(
+		"delete",
+		"Delete a session",
+		func(ctx context.Context, arg string) error {
+			if len(arg) == 0 {
+				return fmt.Errorf("wrong format")
+			}
+			if err := session.Delete(common.PATH_DIR_SESSIONS, arg); err != nil {
+				return err
+			}
+			// The conversation continues in a new session
+			if current != nil && current.ID == arg {
+				current = nil
+			}
+			rnbw.ForegroundColor(rnbw.Green)
+			output.Printf("Successfully deleted session %s\n", arg)
+			rnbw.ResetColor()
+			logger.Log(logger.INFO, "deleted session %s", arg)
+			return nil
+		},
+	))
 	// /acc — delegate to account management
 	r.AddCommand(repl.NewCMD(
 		"acc",
 		"Account management",
-		func(arg string) error {
-			return config.GetAccountManager().HandleCommands(arg)
+		func(ctx context.Context, arg string) error {
+			if err := config.GetAccountManager().HandleCommands(arg); err != nil {
+				return err
+			}
+			checkAgentMode(config, llmClient)
+			return nil
 		},
 	))
 	// /model - change model per current account (persisted in config)
 	r.AddCommand(repl.NewCMD(
 		"model",
 		"Change model",
-		func(arg string) error {
+		func(ctx context.Context, arg string) error {
 			if len(arg) == 0 {
 				return fmt.Errorf("wrong format")
 			}
@@ -286,21 +709,30 @@ func Run() {
 			output.Printf("Successfully changed model to '%s' for account '%s'\n", arg, account.Name)
 			rnbw.ResetColor()
 			logger.Log(logger.INFO, "changed model to '%s' for account '%s'", arg, account.Name)
+			checkAgentMode(config, llmClient)
 			return nil
 		},
 	))
 	// /models — fetch and list available models from the current API
+	// /models pull <name> — download a model (Ollama only)
 	r.AddCommand(repl.NewCMD(
 		"models",
 		"List all models",
-		func(arg string) error {
-			// Get current account
-			account, err := config.GetAccountManager().GetCurrentAccount()
+		func(ctx context.Context, arg string) error {
+			// Create the provider of the current account
+			_, provider, err := newProvider(config)
 			if err != nil {
 				return err
 			}
+			// Download a model
+			if args := strings.Fields(arg); len(args) > 0 {
+				if args[0] != "pull" || len(args) != 2 {
+					return fmt.Errorf("wrong format")
+				}
+				return pullModel(ctx, provider, args[1])
+			}
 			// Get available models
-			models, err := api.GetModels(account.ApiUrl, account.ApiKey)
+			models, err := api.GetModels(ctx, provider)
 			logger.Log(logger.INFO, "%s", "fetching available models")
 			if err != nil {
 				return err
@@ -315,7 +747,7 @@ func Run() {
 	r.AddCommand(repl.NewCMD(
 		"init",
 		"Initialize project",
-		func(arg string) error {
+		func(ctx context.Context, arg string) error {
 			err := setup.SetupProjectDir()
 			if err != nil {
 				return err
@@ -350,6 +782,282 @@ func Run() {
 	} else {
 		output.PrintWarning("no account\n")
 	}
+	// Resume a saved session
+	if len(*args.ResumeFlag) > 0 || *args.ContinueFlag {
+		var s *session.Session
+		if len(*args.ResumeFlag) > 0 {
+			s, err = session.Load(common.PATH_DIR_SESSIONS, *args.ResumeFlag)
+		} else {
+			s, err = session.Latest(common.PATH_DIR_SESSIONS)
+		}
+		if err != nil {
+			output.PrintfWarning("%v\n", err)
+			logger.Log(logger.WARNING, "failed to resume session: %v", err)
+		} else {
+			current = s
+			resumeSession(s, config, llmClient)
+		}
+	}
 	// Run REPL
 	r.Run()
+	// Append the session totals to the output file
+	if turns := llmClient.GetTurns(); len(turns) > 0 {
+		output.SetWriteMode(output.FILE)
+		output.Println()
+		output.Println("## Usage")
+		output.Println()
+		output.Println("```")
+		output.Print(llmx.CostReport(turns, config.GetPrices(), config.GetCurrency()))
+		output.Println("```")
+		output.SetWriteMode(output.ALL)
+	}
+}
+
+// newProvider creates the API provider for the current account.
+// The endpoint of the account is combined with the global retry policy.
+func newProvider(cfg *config.Config) (*acc.Account, api.Provider, error) {
+	// Get current account
+	account, err := cfg.GetAccountManager().GetCurrentAccount()
+	if err != nil {
+		return nil, nil, err
+	}
+	endpoint := account.Endpoint()
+	endpoint.Retry = cfg.GetRetryPolicy()
+	provider, err := api.NewProvider(endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	return account, provider, nil
+}
+
+// newTarget creates the LLM target (provider, model and context window) of the current account.
+// If model is empty, the model of the account is used.
+func newTarget(cfg *config.Config, model string) (llmx.Target, error) {
+	account, provider, err := newProvider(cfg)
+	if err != nil {
+		return llmx.Target{}, err
+	}
+	if len(model) == 0 {
+		model = account.GetModel()
+	}
+	return llmx.Target{Provider: provider, Model: model, ContextWindow: account.ContextWindowFor(model)}, nil
+}
+
+// supportsTools returns an error if the provider of the current account does not support tool calling.
+func supportsTools(cfg *config.Config) error {
+	_, provider, err := newProvider(cfg)
+	if err != nil {
+		return err
+	}
+	if _, ok := provider.(api.ToolCaller); !ok {
+		return fmt.Errorf("the provider of the account does not support tool calling")
+	}
+	return nil
+}
+
+// checkAgentMode switches agent mode off with a warning if the provider of the current account
+// does not support tool calling. Without a usable account, agent mode is left unchanged.
+func checkAgentMode(cfg *config.Config, llmClient *llmx.LLMx) {
+	if !llmClient.AgentMode() {
+		return
+	}
+	_, provider, err := newProvider(cfg)
+	if err != nil {
+		return
+	}
+	if _, ok := provider.(api.ToolCaller); !ok {
+		llmClient.SetAgentMode(false)
+		output.PrintlnWarning("agent mode is off, the provider of the account does not support tool calling")
+		logger.Log(logger.WARNING, "%s", "disabled agent mode, the provider does not support tool calling")
+	}
+}
+
+// runBash runs a shell command if the command policy allows it.
+// The output is streamed to the terminal (and transcript) and followed by the exit status.
+func runBash(ctx context.Context, command string, commands *policy.Checker, opts executor.Options) (executor.Result, error) {
+	if err := commands.Confirm(command); err != nil {
+		return executor.Result{}, err
+	}
+	stream := &tailWriter{w: output.Writer(), last: '\n'}
+	opts.Stream = stream
+	res, err := executor.Run(ctx, command, opts)
+	policy.Record("/bash", command, res.ExitCode, err)
+	if err != nil {
+		return res, err
+	}
+	if stream.last != '\n' {
+		output.Println()
+	}
+	if res.Isolate && !res.Isolated {
+		output.PrintlnWarning("not isolated, bubblewrap is not available")
+	}
+	if res.ExitCode == 0 {
+		rnbw.ForegroundColor(rnbw.Gray)
+	} else {
+		rnbw.ForegroundColor(rnbw.Red)
+	}
+	output.Println(res.Status())
+	rnbw.ResetColor()
+	return res, nil
+}
+
+// tailWriter forwards writes to w and remembers the last written byte.
+type tailWriter struct {
+	w    io.Writer
+	last byte
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		t.last = p[len(p)-1]
+	}
+	return t.w.Write(p)
+}
+
+// streamResponse runs send and prints the response chunks while they are streamed.
+// The response is separated from the prompt by an empty line.
+func streamResponse(send func(onChunk func(string)) error) error {
+	streamed := false
+	err := send(func(chunk string) {
+		if !streamed {
+			output.Println()
+			streamed = true
+		}
+		output.Print(chunk)
+	})
+	if streamed {
+		output.Println()
+	}
+	return err
+}
+
+// saveSession stores the conversation of the LLM client in the session and saves it.
+func saveSession(s *session.Session, cfg *config.Config, llmClient *llmx.LLMx) error {
+	accountName := ""
+	model := ""
+	if account, err := cfg.GetAccountManager().GetCurrentAccount(); err == nil {
+		accountName = account.Name
+		model = account.GetModel()
+	}
+	s.Update(accountName, model, llmClient)
+	if err := s.Save(common.PATH_DIR_SESSIONS); err != nil {
+		return fmt.Errorf("saving session: %w", err)
+	}
+	logger.Log(logger.INFO, "saved session %s", s.ID)
+	return nil
+}
+
+// resumeSession restores the conversation of the session in the LLM client.
+// A warning is shown if the session was held with another account.
+func resumeSession(s *session.Session, cfg *config.Config, llmClient *llmx.LLMx) {
+	s.Restore(llmClient)
+	rnbw.ForegroundColor(rnbw.Green)
+	output.Printf("Resumed session %s '%s' (%d messages)\n", s.ID, s.Title, len(s.Messages))
+	rnbw.ResetColor()
+	if account, err := cfg.GetAccountManager().GetCurrentAccount(); err != nil || account.Name != s.Account {
+		output.PrintfWarning("the session was held with account '%s' and model '%s'\n", s.Account, s.Model)
+	}
+	logger.Log(logger.INFO, "resumed session %s", s.ID)
+}
+
+// changesSince compares the snapshot of a turn (empty for the last one) with the current working tree.
+func changesSince(store *snapshot.Store, turn string) (*snapshot.Manifest, []snapshot.Change, error) {
+	var m *snapshot.Manifest
+	var err error
+	if len(turn) == 0 {
+		m, err = store.Latest()
+	} else {
+		n, convErr := strconv.Atoi(turn)
+		if convErr != nil {
+			return nil, nil, fmt.Errorf("invalid turn '%s'", turn)
+		}
+		m, err = store.Load(n)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	paths, err := codebase.ListFiles(".")
+	if err != nil {
+		return nil, nil, err
+	}
+	changes, err := store.Changes(m, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, changes, nil
+}
+
+// printSkipped prints the files left out of the codebase with the reason and size.
+func printSkipped(skipped []codebase.Skipped) {
+	if len(skipped) == 0 {
+		return
+	}
+	rnbw.ForegroundColor(rnbw.Gray)
+	output.Printf("\nSkipped %s:\n", codebase.SkippedSummary(skipped))
+	for _, s := range skipped {
+		output.Printf("  %-11s %8d bytes  %s", s.Reason, s.Size, filepath.ToSlash(s.Path))
+		if s.Err != nil {
+			output.Printf(" (%v)", s.Err)
+		}
+		output.Println()
+	}
+	rnbw.ResetColor()
+}
+
+// printChanges prints the changed files with the number of added and removed lines.
+func printChanges(changes []snapshot.Change) {
+	if len(changes) == 0 {
+		output.Println("No changes")
+		return
+	}
+	for _, c := range changes {
+		output.Printf("  %-8s ", c.Kind)
+		rnbw.ForegroundColor(rnbw.Green)
+		output.Printf("%+5d ", c.Added)
+		rnbw.ForegroundColor(rnbw.Red)
+		output.Printf("%5s ", fmt.Sprintf("-%d", c.Removed))
+		rnbw.ResetColor()
+		output.Println(c.Path)
+	}
+}
+
+// pullModel downloads a model through the provider and shows the download progress.
+// Only progress lines are written to stdout, the final result is also written to the output file.
+func pullModel(ctx context.Context, provider api.Provider, name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("wrong format")
+	}
+	puller, ok := provider.(api.ModelPuller)
+	if !ok {
+		return fmt.Errorf("provider does not support pulling models")
+	}
+	logger.Log(logger.INFO, "pulling model '%s'", name)
+	status := ""
+	output.SetWriteMode(output.STDOUT)
+	err := puller.PullModel(ctx, name, func(p api.PullProgress) {
+		// Start a new line for every new status
+		if p.Status != status {
+			if len(status) > 0 {
+				output.Println()
+			}
+			status = p.Status
+		}
+		if p.Total > 0 {
+			output.Printf("\r\033[K%s %d%%", p.Status, p.Completed*100/p.Total)
+		} else {
+			output.Printf("\r\033[K%s", p.Status)
+		}
+	})
+	if len(status) > 0 {
+		output.Println()
+	}
+	output.SetWriteMode(output.ALL)
+	if err != nil {
+		return err
+	}
+	rnbw.ForegroundColor(rnbw.Green)
+	output.Printf("Successfully pulled model '%s'\n", name)
+	rnbw.ResetColor()
+	logger.Log(logger.INFO, "pulled model '%s'", name)
+	return nil
 }
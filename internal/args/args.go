@@ -18,6 +18,10 @@ var (
 	OutputFlag = flag.Bool("o", false, "Write to output file")
 	// LogFlag is a flag to enable logging.
 	LogFlag = flag.Bool("l", false, "Enable logging")
+	// ResumeFlag is a flag to resume a saved session by ID.
+	ResumeFlag = flag.String("r", "", "Resume the session with the given ID")
+	// ContinueFlag is a flag to resume the latest session.
+	ContinueFlag = flag.Bool("continue", false, "Resume the latest session")
 )
 
 func init() {
@@ -32,6 +36,7 @@ func init() {
 		fmt.Fprintf(os.Stderr, "  %s -i           Initialize project\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -v           Show version\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -i -o -l     Init with output and logging\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s --continue   Resume the latest session\n", os.Args[0])
 	}
 }
 
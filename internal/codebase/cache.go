@@ -0,0 +1,101 @@
+package codebase
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/thxrsxm/harzmind-code/internal/common"
+)
+
+// cacheEntry is the content of a file as of its modification time and size.
+type cacheEntry struct {
+	modTime time.Time
+	size    int64
+	// content is empty for binary files.
+	content string
+	// binary and generated are the results of the content checks.
+	binary    bool
+	generated bool
+}
+
+// fileCache keeps the contents of the files read by Scan, so that only changed files are read again.
+// Files are considered unchanged while their modification time and size are the same.
+// The cache is cleared when the .hzmignore file changes.
+type fileCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// ignoreStamp is the modification time and size of the .hzmignore file when the cache was filled.
+	ignoreStamp string
+}
+
+// cache is the file cache shared by all scans.
+var cache *fileCache = &fileCache{entries: map[string]cacheEntry{}}
+
+// stamp returns the modification time and size of a file, empty if it does not exist.
+func stamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%d", info.ModTime(), info.Size())
+}
+
+// validate clears the cache if the .hzmignore file has changed since the last scan.
+func (c *fileCache) validate() {
+	s := stamp(common.PATH_FILE_IGNORE)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s != c.ignoreStamp {
+		clear(c.entries)
+		c.ignoreStamp = s
+	}
+}
+
+// read returns the cached entry of a file if its modification time and size are unchanged,
+// otherwise it reads and checks the file and caches the result.
+func (c *fileCache) read(path string, info fs.FileInfo) (cacheEntry, error) {
+	c.mu.Lock()
+	e, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+		return e, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return cacheEntry{}, err
+	}
+	e = cacheEntry{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		binary:  isBinary(content),
+	}
+	if !e.binary {
+		e.content = string(content)
+		e.generated = generatedHeader.Match(content)
+	}
+	c.mu.Lock()
+	c.entries[path] = e
+	c.mu.Unlock()
+	return e, nil
+}
+
+// prune removes the entries of the files below root that were not seen by a scan.
+func (c *fileCache) prune(root string, seen map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for path := range c.entries {
+		if !seen[path] && within(root, path) {
+			delete(c.entries, path)
+		}
+	}
+}
+
+// within reports whether the path is below the root directory.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && filepath.IsLocal(rel)
+}
@@ -0,0 +1,69 @@
+package codebase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanCache(t *testing.T) {
+	t.Chdir(t.TempDir())
+	mtime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// write writes the file and resets its modification time
+	write := func(path, content string, mtime time.Time) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// content scans the project and returns the content of a.txt
+	content := func() string {
+		t.Helper()
+		files, _, err := Scan(".")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			if f.Path == "a.txt" {
+				return f.Content
+			}
+		}
+		return ""
+	}
+	write("a.txt", "one", mtime)
+	write("b.txt", "two", mtime)
+	if got := content(); got != "one" {
+		t.Fatalf("Scan() = %q, want %q", got, "one")
+	}
+	// Same modification time and size: the cached content is used
+	write("a.txt", "uno", mtime)
+	if got := content(); got != "one" {
+		t.Errorf("Scan() of an unchanged file = %q, want the cached %q", got, "one")
+	}
+	// A new modification time: the file is read again
+	write("a.txt", "uno", mtime.Add(time.Second))
+	if got := content(); got != "uno" {
+		t.Errorf("Scan() of a modified file = %q, want %q", got, "uno")
+	}
+	// A changed .hzmignore file clears the cache
+	write("a.txt", "dos", mtime.Add(time.Second))
+	write(filepath.Join("hzmind", ".hzmignore"), "*.log\n", mtime)
+	if got := content(); got != "dos" {
+		t.Errorf("Scan() after changing .hzmignore = %q, want %q", got, "dos")
+	}
+	// Removed files are dropped from the cache
+	if err := os.Remove("b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	content()
+	if _, ok := cache.entries["b.txt"]; ok {
+		t.Error("cache entry of a removed file was kept")
+	}
+}
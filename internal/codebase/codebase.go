@@ -1,39 +1,33 @@
 // Package codebase provides functionality for retrieving the project's codebase,
 // including reading files with optional ignore patterns, generating file trees for visualization,
-// and handling ignore rules defined in .hzmignore files.
+// and handling ignore rules defined in .hzmignore and .gitignore files.
 package codebase
 
 import (
-	"bufio"
 	"fmt"
-	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
 
-	ignore "github.com/sabhiram/go-gitignore"
 	"github.com/thxrsxm/harzmind-code/internal/common"
+	"github.com/thxrsxm/harzmind-code/internal/secrets"
 )
 
-// createIgnorer creates a new ignore compiler based on the ignore patterns.
-// It also reads additional ignore patterns from a .hzmignore file if it exists.
-func createIgnorer() *ignore.GitIgnore {
-	// Start with predefined ignore patterns
-	patterns := make([]string, len(ignorePatterns))
-	copy(patterns, ignorePatterns)
-	// Check if .hzmignore file exists and add its patterns
-	if file, err := os.Open(common.PATH_FILE_IGNORE); err == nil {
-		defer file.Close()
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			// Ignore empty lines and comments
-			if line != "" && line[0] != '#' {
-				patterns = append(patterns, line)
-			}
-		}
-	}
-	return ignore.CompileIgnoreLines(patterns...)
+// IsIgnored reports whether a path relative to the project root is excluded by the ignore patterns
+// or one of its parent directories is excluded. A path that does not exist is treated as a file.
+func IsIgnored(path string) bool {
+	ignored, _ := ExplainIgnored(path)
+	return ignored
+}
+
+// ExplainIgnored reports whether a path relative to the project root is ignored and returns the rule
+// that decided it, nil if no rule matches the path. The rule of an ignored parent directory
+// decides for all paths below it.
+func ExplainIgnored(path string) (bool, *IgnoreRule) {
+	info, err := os.Stat(path)
+	isDir := err == nil && info.IsDir()
+	return createIgnorer().explain(path, isDir)
 }
 
 // IgnoreFileExists checks if the .hzmignore file exists.
@@ -43,17 +37,94 @@ func IgnoreFileExists() bool {
 
 // GetCodeBase retrieves a list of files within the given root directory,
 // excluding files and directories based on ignore patterns.
+// Binary, oversized, generated and sensitive files are skipped (see Scan).
 func GetCodeBase(root string) ([]File, error) {
-	files := []File{}
+	files, _, err := Scan(root)
+	return files, err
+}
+
+// ListFiles returns the paths of all files within the root directory that are not ignored,
+// regardless of the limits and the content checks of Scan. Unreadable directories are left out.
+func ListFiles(root string) ([]string, error) {
 	ignorer := createIgnorer()
-	// Walk through the directory tree
+	paths := []string{}
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			// Error accessing file/directory
-			return err
+			if path == root {
+				return err
+			}
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ignorer.ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking directory: %w", err)
+	}
+	return paths, nil
+}
+
+// Scan retrieves the files within the given root directory like GetCodeBase
+// and also returns the files that were skipped because of their content or the limits (see SetLimits).
+// The contents are cached by path, modification time and size, so only changed files are read again.
+//
+// While the directory tree is walked, the files are read by a bounded pool of readers.
+// The files are returned in walk order. Files and directories that cannot be read are skipped
+// with SKIP_UNREADABLE and do not abort the scan, only an unreadable root does.
+func Scan(root string) ([]File, []Skipped, error) {
+	ignorer := createIgnorer()
+	// The sensitive files stay blocked if the allowlist cannot be read
+	allow, _ := secrets.LoadAllowlist(common.PATH_FILE_SECRETS_ALLOWLIST)
+	cache.validate()
+	// Start the readers and the collector of their results
+	jobs := make(chan readJob, 2*numReaders)
+	results := make(chan readResult, 2*numReaders)
+	var wg sync.WaitGroup
+	for range numReaders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				entry, err := cache.read(job.path, job.info)
+				results <- readResult{index: job.index, entry: entry, err: err}
+			}
+		}()
+	}
+	read := []readResult{}
+	collected := make(chan struct{})
+	go func() {
+		for r := range results {
+			read = append(read, r)
+		}
+		close(collected)
+	}()
+	// Walk through the directory tree, the items are in walk order
+	items := []scanItem{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			// Error accessing file/directory, skip it
+			items = append(items, scanItem{skip: &Skipped{Path: path, Reason: SKIP_UNREADABLE, Err: err}})
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 		// Check if the current path should be ignored
-		if ignorer.MatchesPath(path) {
+		if ignorer.ignored(path, d.IsDir()) {
 			if d.IsDir() {
 				// Skip entire directory and subdirectories
 				return filepath.SkipDir
@@ -62,29 +133,106 @@ func GetCodeBase(root string) ([]File, error) {
 			return nil
 		}
 		// If we reach this point, the file/directory should not be ignored
-		if !d.IsDir() {
-			// Open the file
-			file, err := os.Open(path)
-			if err != nil {
-				return fmt.Errorf("error opening file %s: %v", path, err)
-			}
-			defer file.Close()
-			// Read the file content
-			content, err := io.ReadAll(file)
-			if err != nil {
-				return fmt.Errorf("error reading file %s: %v", path, err)
-			}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			items = append(items, scanItem{skip: &Skipped{Path: path, Reason: SKIP_UNREADABLE, Err: err}})
+			return nil
+		}
+		// Skip files by name and size before reading them
+		if skip := skipByName(path, info, allow); skip != nil {
+			items = append(items, scanItem{skip: skip})
+			return nil
+		}
+		// Read the file unless it is unchanged since the last scan
+		items = append(items, scanItem{path: path, name: d.Name()})
+		jobs <- readJob{index: len(items) - 1, path: path, info: info}
+		return nil
+	})
+	// Wait for the readers before returning, also after an error
+	close(jobs)
+	wg.Wait()
+	close(results)
+	<-collected
+	if err != nil {
+		return nil, nil, fmt.Errorf("error walking directory: %w", err)
+	}
+	for _, r := range read {
+		items[r.index].entry = r.entry
+		items[r.index].err = r.err
+	}
+	// Skip files by content and apply the total limit in walk order
+	files := []File{}
+	skipped := []Skipped{}
+	seen := map[string]bool{}
+	var total int64
+	for _, item := range items {
+		if item.skip != nil {
+			skipped = append(skipped, *item.skip)
+			continue
+		}
+		if item.err != nil {
+			skipped = append(skipped, Skipped{Path: item.path, Reason: SKIP_UNREADABLE, Err: item.err})
+			continue
+		}
+		seen[item.path] = true
+		entry := item.entry
+		size := int64(len(entry.content))
+		switch {
+		case entry.binary:
+			skipped = append(skipped, Skipped{Path: item.path, Reason: SKIP_BINARY, Size: entry.size})
+		case limits.SkipGenerated && entry.generated:
+			skipped = append(skipped, Skipped{Path: item.path, Reason: SKIP_GENERATED, Size: size})
+		case limits.MaxTotalBytes > 0 && total+size > limits.MaxTotalBytes:
+			skipped = append(skipped, Skipped{Path: item.path, Reason: SKIP_TOTAL, Size: size})
+		default:
 			// Add the file to the list
+			total += size
 			files = append(files, File{
-				Name:    d.Name(),
-				Content: string(content),
-				Path:    path,
+				Name:    item.name,
+				Content: entry.content,
+				Path:    item.path,
 			})
 		}
-		return nil
-	})
+	}
+	cache.prune(root, seen)
+	return files, skipped, nil
+}
+
+// ReadFile reads a single file with the binary and size checks of Scan, e.g. a file referenced by the user.
+// A binary file or a file larger than the file limit is returned as skipped.
+func ReadFile(path string) (File, *Skipped, error) {
+	info, err := os.Stat(path)
 	if err != nil {
-		return nil, fmt.Errorf("error walking directory: %w", err)
+		return File{}, nil, err
+	}
+	if info.IsDir() {
+		return File{}, nil, fmt.Errorf("%s is a directory", path)
+	}
+	if limits.MaxFileBytes > 0 && info.Size() > limits.MaxFileBytes {
+		return File{}, &Skipped{Path: path, Reason: SKIP_TOO_LARGE, Size: info.Size()}, nil
+	}
+	entry, err := cache.read(path, info)
+	if err != nil {
+		return File{}, nil, err
+	}
+	if entry.binary {
+		return File{}, &Skipped{Path: path, Reason: SKIP_BINARY, Size: entry.size}, nil
+	}
+	return File{Name: info.Name(), Content: entry.content, Path: path}, nil, nil
+}
+
+// skipByName returns the reason to skip a file by its name and size, nil if it must be read.
+func skipByName(path string, info fs.FileInfo, allow *secrets.Allowlist) *Skipped {
+	switch {
+	case allow.Blocked(path):
+		return &Skipped{Path: path, Reason: SKIP_SENSITIVE, Size: info.Size()}
+	case limits.SkipGenerated && isGeneratedName(info.Name()):
+		return &Skipped{Path: path, Reason: SKIP_GENERATED, Size: info.Size()}
+	case limits.MaxFileBytes > 0 && info.Size() > limits.MaxFileBytes:
+		return &Skipped{Path: path, Reason: SKIP_TOO_LARGE, Size: info.Size()}
 	}
-	return files, nil
+	return nil
 }
@@ -0,0 +1,129 @@
+package codebase
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	t.Chdir(t.TempDir())
+	files := map[string]string{
+		"main.go":           "package main\n",
+		"gen.go":            "// Code generated by stringer. DO NOT EDIT.\n\npackage main\n",
+		"gen_crlf.go":       "// Code generated by stringer. DO NOT EDIT.\r\n\r\npackage main\r\n",
+		"go.sum":            "example.com/x v1.0.0 h1:abc=\n",
+		"web/app.min.js":    "var a=1;",
+		"image.png":         "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"data.bin":          "abc\x00def",
+		"big.txt":           strings.Repeat("a", 101),
+		"docs/a.md":         strings.Repeat("b", 60),
+		"docs/b.md":         strings.Repeat("c", 60),
+		"node_modules/x.js": "ignored",
+		".env":              "TOKEN=x",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer SetLimits(limits)
+	SetLimits(Limits{MaxFileBytes: 100, MaxTotalBytes: 100, SkipGenerated: true})
+	got, skipped, err := Scan(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, f := range got {
+		paths = append(paths, filepath.ToSlash(f.Path))
+	}
+	if want := []string{"docs/a.md", "main.go"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("Scan() files = %v, want %v", paths, want)
+	}
+	reasons := map[string]string{}
+	for _, s := range skipped {
+		reasons[filepath.ToSlash(s.Path)] = s.Reason
+	}
+	want := map[string]string{
+		".env":           SKIP_SENSITIVE,
+		"big.txt":        SKIP_TOO_LARGE,
+		"data.bin":       SKIP_BINARY,
+		"docs/b.md":      SKIP_TOTAL,
+		"gen.go":         SKIP_GENERATED,
+		"gen_crlf.go":    SKIP_GENERATED,
+		"go.sum":         SKIP_GENERATED,
+		"image.png":      SKIP_BINARY,
+		"web/app.min.js": SKIP_GENERATED,
+	}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("Scan() skipped = %v, want %v", reasons, want)
+	}
+	if got, want := SkippedSummary(skipped), "9 files (2 binary, 4 generated, 1 sensitive, 1 too large, 1 total limit)"; got != want {
+		t.Errorf("SkippedSummary() = %q, want %q", got, want)
+	}
+	// A single file is read with the same checks, but without the total limit
+	for path, reason := range map[string]string{"big.txt": SKIP_TOO_LARGE, "data.bin": SKIP_BINARY, "docs/b.md": ""} {
+		f, skip, err := ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (skip == nil && (len(reason) > 0 || f.Content != files[path])) || (skip != nil && skip.Reason != reason) {
+			t.Errorf("ReadFile(%q) = %+v, %+v, want reason %q", path, f, skip, reason)
+		}
+	}
+}
+
+func TestScanOrderAndUnreadable(t *testing.T) {
+	t.Chdir(t.TempDir())
+	want := []string{}
+	for i := range 40 {
+		path := filepath.Join(fmt.Sprintf("dir%d", i%4), fmt.Sprintf("file%02d.txt", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("missing.txt", "broken.txt"); err != nil {
+		t.Fatal(err)
+	}
+	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if !d.IsDir() && path != "broken.txt" {
+			want = append(want, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		files, skipped, err := Scan(".")
+		if err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		paths := []string{}
+		for _, f := range files {
+			if f.Content != f.Path {
+				t.Errorf("Scan() content of %s = %q", f.Path, f.Content)
+			}
+			paths = append(paths, f.Path)
+		}
+		if !reflect.DeepEqual(paths, want) {
+			t.Fatalf("Scan() files = %v, want %v", paths, want)
+		}
+		if len(skipped) != 1 || skipped[0].Path != "broken.txt" || skipped[0].Reason != SKIP_UNREADABLE || skipped[0].Err == nil {
+			t.Errorf("Scan() skipped = %+v, want the unreadable broken.txt", skipped)
+		}
+	}
+	if _, _, err := Scan("missing"); err == nil {
+		t.Error("Scan() of a missing root succeeded")
+	}
+}
@@ -18,3 +18,17 @@ var ignorePatterns []string = []string{
 	common.FILE_LOG,
 	common.DIR_MAIN + "/",
 }
+
+// generatedPatterns is a list of file name patterns of generated files, lockfiles and minified bundles.
+var generatedPatterns []string = []string{
+	"*.min.js",
+	"*.min.css",
+	"*.map",
+	"go.sum",
+	"package-lock.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"Cargo.lock",
+	"composer.lock",
+	"poetry.lock",
+}
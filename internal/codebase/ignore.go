@@ -0,0 +1,323 @@
+package codebase
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/thxrsxm/harzmind-code/internal/common"
+)
+
+// SOURCE_DEFAULT is the source of the rules of the predefined ignore patterns.
+const SOURCE_DEFAULT string = "default patterns"
+
+// FILE_GITIGNORE is the name of git's ignore files.
+const FILE_GITIGNORE string = ".gitignore"
+
+// pathGitExclude is the path of the repository's exclude file.
+var pathGitExclude string = filepath.Join(".git", "info", "exclude")
+
+// IgnoreRule is a line of an ignore file.
+type IgnoreRule struct {
+	// Source is the path of the ignore file (or SOURCE_DEFAULT).
+	Source string
+	// Line is the 1-based line number in the source.
+	Line int
+	// Pattern is the line as written in the source.
+	Pattern string
+	// Negated reports whether the rule re-includes paths (`!pattern`).
+	Negated bool
+	// base is the directory the rule is relative to ("" for the project root).
+	base string
+	// dirOnly reports whether the rule only matches directories (`pattern/`).
+	dirOnly bool
+	// re matches paths relative to base.
+	re *regexp.Regexp
+}
+
+// matches reports whether the rule matches the path relative to the project root.
+func (r *IgnoreRule) matches(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if len(r.base) > 0 {
+		if !strings.HasPrefix(p, r.base+"/") {
+			return false
+		}
+		p = p[len(r.base)+1:]
+	}
+	return r.re.MatchString(p)
+}
+
+// ignorer decides which paths are ignored, following the rules of git:
+// the last matching rule wins, the rules of `.gitignore` files apply to the paths below their directory
+// and deeper files take precedence. The paths below an ignored directory are always ignored.
+//
+// The rules are applied in this order: the global git excludes (if enabled), `.git/info/exclude`,
+// the `.gitignore` files from the root down, the default patterns and `.hzmignore`.
+type ignorer struct {
+	// before are the rules applied before the `.gitignore` files.
+	before []*IgnoreRule
+	// after are the rules applied after the `.gitignore` files.
+	after []*IgnoreRule
+	// dirs caches the rules of the `.gitignore` file of every directory.
+	dirs map[string][]*IgnoreRule
+	// ignoredDirs caches the decision for directories.
+	ignoredDirs map[string]*IgnoreRule
+}
+
+// createIgnorer creates a new ignorer from the ignore files of the project and the predefined ignore patterns.
+func createIgnorer() *ignorer {
+	ig := &ignorer{
+		dirs:        map[string][]*IgnoreRule{},
+		ignoredDirs: map[string]*IgnoreRule{},
+	}
+	if limits.GlobalExcludes {
+		if path := globalExcludesFile(); len(path) > 0 {
+			ig.before = append(ig.before, readIgnoreFile(path, "")...)
+		}
+	}
+	ig.before = append(ig.before, readIgnoreFile(pathGitExclude, "")...)
+	ig.after = append(ig.after, parseIgnoreLines(ignorePatterns, SOURCE_DEFAULT, "")...)
+	ig.after = append(ig.after, readIgnoreFile(common.PATH_FILE_IGNORE, "")...)
+	return ig
+}
+
+// match returns the rule that decides whether the path is ignored, ignoring its parent directories.
+// It returns nil if no rule matches.
+func (ig *ignorer) match(p string, isDir bool) *IgnoreRule {
+	var last *IgnoreRule
+	check := func(rules []*IgnoreRule) {
+		for _, r := range rules {
+			if r.matches(p, isDir) {
+				last = r
+			}
+		}
+	}
+	check(ig.before)
+	// The `.gitignore` files of the root and of every parent directory
+	dir := ""
+	check(ig.gitignore(dir))
+	for _, part := range strings.Split(path.Dir(p), "/") {
+		if part == "." {
+			break
+		}
+		dir = path.Join(dir, part)
+		check(ig.gitignore(dir))
+	}
+	check(ig.after)
+	return last
+}
+
+// explain returns whether the path is ignored and the deciding rule (nil if none).
+// A path below an ignored directory is ignored by the rule of that directory.
+func (ig *ignorer) explain(p string, isDir bool) (bool, *IgnoreRule) {
+	p = normalizePath(p)
+	if p == "." {
+		return false, nil
+	}
+	// Check the parent directories from the top
+	parts := strings.Split(p, "/")
+	for i := 1; i < len(parts); i++ {
+		if ignored, r := ig.dir(strings.Join(parts[:i], "/")); ignored {
+			return true, r
+		}
+	}
+	r := ig.match(p, isDir)
+	return r != nil && !r.Negated, r
+}
+
+// dir returns the decision for a directory, which is cached for its subpaths.
+func (ig *ignorer) dir(p string) (bool, *IgnoreRule) {
+	r, ok := ig.ignoredDirs[p]
+	if !ok {
+		r = ig.match(p, true)
+		ig.ignoredDirs[p] = r
+	}
+	return r != nil && !r.Negated, r
+}
+
+// ignored reports whether a path found while walking the project is ignored.
+// Its parent directories must not be ignored.
+func (ig *ignorer) ignored(p string, isDir bool) bool {
+	p = normalizePath(p)
+	if p == "." {
+		return false
+	}
+	if isDir {
+		ignored, _ := ig.dir(p)
+		return ignored
+	}
+	r := ig.match(p, false)
+	return r != nil && !r.Negated
+}
+
+// gitignore returns the rules of the `.gitignore` file of a directory.
+func (ig *ignorer) gitignore(dir string) []*IgnoreRule {
+	rules, ok := ig.dirs[dir]
+	if !ok {
+		rules = readIgnoreFile(path.Join(dir, FILE_GITIGNORE), dir)
+		ig.dirs[dir] = rules
+	}
+	return rules
+}
+
+// normalizePath returns the cleaned path with forward slashes.
+func normalizePath(p string) string {
+	return path.Clean(filepath.ToSlash(p))
+}
+
+// readIgnoreFile reads the rules of an ignore file relative to the base directory.
+// A missing or unreadable file has no rules.
+func readIgnoreFile(path, base string) []*IgnoreRule {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return parseIgnoreLines(lines, filepath.ToSlash(path), base)
+}
+
+// parseIgnoreLines parses the lines of an ignore file.
+// Empty lines and comments are skipped.
+func parseIgnoreLines(lines []string, source, base string) []*IgnoreRule {
+	rules := []*IgnoreRule{}
+	for i, line := range lines {
+		if r := parseIgnoreLine(line); r != nil {
+			r.Source = source
+			r.Line = i + 1
+			r.base = base
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreLine parses a line in the gitignore format, nil for empty lines and comments.
+func parseIgnoreLine(line string) *IgnoreRule {
+	line = strings.TrimSuffix(line, "\r")
+	r := &IgnoreRule{Pattern: line}
+	// Trailing spaces are removed unless they are escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if len(line) == 0 || line[0] == '#' {
+		return nil
+	}
+	if line[0] == '!' {
+		r.Negated = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if len(line) == 0 {
+		return nil
+	}
+	// A slash at the start or in the middle anchors the pattern to the directory of the ignore file
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	prefix := "^(?:.*/)?"
+	if anchored {
+		prefix = "^"
+	}
+	re, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+	if err != nil {
+		return nil
+	}
+	r.re = re
+	return r
+}
+
+// globToRegexp converts a gitignore glob to a regular expression.
+// `*` and `?` do not match a slash, `**` matches any number of directories.
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**") {
+				atStart := i == 0 || glob[i-1] == '/'
+				rest := glob[i+2:]
+				switch {
+				case atStart && strings.HasPrefix(rest, "/"):
+					// `**/` matches zero or more directories
+					sb.WriteString("(?:.*/)?")
+					i += 2
+				case atStart && len(rest) == 0:
+					// A trailing `/**` matches everything inside
+					sb.WriteString(".*")
+					i++
+				default:
+					sb.WriteString("[^/]*")
+					i++
+				}
+				continue
+			}
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+var (
+	globalExcludesOnce sync.Once
+	globalExcludesPath string
+)
+
+// globalExcludesFile returns the path of git's global excludes file (`core.excludesFile`,
+// by default `$XDG_CONFIG_HOME/git/ignore`), empty if it cannot be determined.
+func globalExcludesFile() string {
+	globalExcludesOnce.Do(func() {
+		home, _ := os.UserHomeDir()
+		if out, err := exec.Command("git", "config", "--get", "core.excludesFile").Output(); err == nil {
+			p := strings.TrimSpace(string(out))
+			if strings.HasPrefix(p, "~/") && len(home) > 0 {
+				p = filepath.Join(home, p[2:])
+			}
+			globalExcludesPath = p
+			return
+		}
+		if dir := os.Getenv("XDG_CONFIG_HOME"); len(dir) > 0 {
+			globalExcludesPath = filepath.Join(dir, "git", "ignore")
+		} else if len(home) > 0 {
+			globalExcludesPath = filepath.Join(home, ".config", "git", "ignore")
+		}
+	})
+	return globalExcludesPath
+}
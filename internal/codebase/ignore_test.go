@@ -0,0 +1,113 @@
+package codebase
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIgnoreLine(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "dir/a.log", false, true},
+		{"*.log", "a.log.txt", false, false},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"dist/", "dist", true, true},
+		{"dist/", "dist", false, false},
+		{"dist/", "web/dist", true, true},
+		{"doc/*.md", "doc/a.md", false, true},
+		{"doc/*.md", "doc/sub/a.md", false, false},
+		{"doc/*.md", "x/doc/a.md", false, false},
+		{"**/out", "a/b/out", true, true},
+		{"**/out", "out", true, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**", "a/x/y", false, true},
+		{"a/**", "a", true, false},
+		{"file?.txt", "file1.txt", false, true},
+		{"file?.txt", "file/.txt", false, false},
+		{"[abc].go", "b.go", false, true},
+		{"[!abc].go", "b.go", false, false},
+		{"\\#notes", "#notes", false, true},
+		{"trailing\\ ", "trailing ", false, true},
+		{".env  ", ".env", false, true},
+	}
+	for _, tt := range tests {
+		r := parseIgnoreLine(tt.pattern)
+		if r == nil {
+			t.Errorf("parseIgnoreLine(%q) = nil", tt.pattern)
+			continue
+		}
+		if got := r.matches(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("%q matches %q (dir: %t) = %t, want %t", tt.pattern, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		if r := parseIgnoreLine(line); r != nil {
+			t.Errorf("parseIgnoreLine(%q) = %+v, want nil", line, r)
+		}
+	}
+}
+
+func TestExplainIgnored(t *testing.T) {
+	t.Chdir(t.TempDir())
+	files := map[string]string{
+		".gitignore":        "*.log\n!keep.log\ndist/\n/secret.txt\n",
+		"sub/.gitignore":    "/local.txt\n!*.log\n",
+		".git/info/exclude": "scratch/\n",
+		"hzmind/.hzmignore": "*.tmp\n!vendor\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll("dist", 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path   string
+		want   bool
+		source string
+		line   int
+	}{
+		{"a.log", true, ".gitignore", 1},
+		{"keep.log", false, ".gitignore", 2},
+		{"dist/app.js", true, ".gitignore", 3},
+		{"secret.txt", true, ".gitignore", 4},
+		{"sub/secret.txt", false, "", 0},
+		{"local.txt", false, "", 0},
+		{"sub/local.txt", true, "sub/.gitignore", 1},
+		{"sub/a.log", false, "sub/.gitignore", 2},
+		{"scratch/x.go", true, ".git/info/exclude", 1},
+		{"x.tmp", true, "hzmind/.hzmignore", 1},
+		{"vendor", false, "hzmind/.hzmignore", 2},
+		{"vendor/x.go", false, "", 0},
+		{"node_modules/x.js", true, SOURCE_DEFAULT, 4},
+		{"main.go", false, "", 0},
+	}
+	for _, tt := range tests {
+		got, rule := ExplainIgnored(tt.path)
+		if got != tt.want {
+			t.Errorf("ExplainIgnored(%q) = %t, want %t", tt.path, got, tt.want)
+		}
+		if len(tt.source) == 0 {
+			if rule != nil {
+				t.Errorf("ExplainIgnored(%q) rule = %s:%d, want none", tt.path, rule.Source, rule.Line)
+			}
+			continue
+		}
+		if rule == nil || rule.Source != tt.source || rule.Line != tt.line {
+			t.Errorf("ExplainIgnored(%q) rule = %+v, want %s:%d", tt.path, rule, tt.source, tt.line)
+		}
+	}
+}
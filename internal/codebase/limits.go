@@ -0,0 +1,111 @@
+package codebase
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// sniffLength is the number of bytes inspected to detect binary files.
+const sniffLength int = 8000
+
+// Skip reasons.
+const (
+	SKIP_BINARY     string = "binary"
+	SKIP_TOO_LARGE  string = "too large"
+	SKIP_TOTAL      string = "total limit"
+	SKIP_GENERATED  string = "generated"
+	SKIP_SENSITIVE  string = "sensitive"
+	SKIP_UNREADABLE string = "unreadable"
+)
+
+// generatedHeader matches the header of generated Go (and similar) source files, also with CRLF line endings.
+var generatedHeader *regexp.Regexp = regexp.MustCompile(`(?m)^// Code generated .* DO NOT EDIT\.\r?$`)
+
+// Limits controls which files are read into the codebase.
+type Limits struct {
+	// MaxFileBytes is the maximum size of a single file (0 disables the limit).
+	MaxFileBytes int64 `json:"maxFileBytes"`
+	// MaxTotalBytes is the maximum size of all files, files beyond it are skipped (0 disables the limit).
+	MaxTotalBytes int64 `json:"maxTotalBytes"`
+	// SkipGenerated skips generated files, lockfiles and minified bundles.
+	SkipGenerated bool `json:"skipGenerated"`
+	// GlobalExcludes also applies git's global excludes file (`core.excludesFile`).
+	GlobalExcludes bool `json:"globalExcludes"`
+}
+
+// DefaultLimits returns the default limits: 256 KB per file, 4 MB in total and no generated files.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxFileBytes:  256 * 1024,
+		MaxTotalBytes: 4 * 1024 * 1024,
+		SkipGenerated: true,
+	}
+}
+
+// limits are the limits applied by GetCodeBase and Scan.
+var limits Limits = DefaultLimits()
+
+// SetLimits sets the limits applied by GetCodeBase and Scan.
+func SetLimits(l Limits) {
+	limits = l
+}
+
+// Skipped describes a file that was left out of the codebase.
+type Skipped struct {
+	Path   string
+	Reason string
+	Size   int64
+	// Err is the error of an unreadable file.
+	Err error
+}
+
+// isGeneratedName reports whether the file name belongs to a generated file.
+func isGeneratedName(name string) bool {
+	for _, pattern := range generatedPatterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isBinary reports whether the content is binary: it contains a NUL byte or is not detected as text.
+func isBinary(content []byte) bool {
+	head := content[:min(len(content), sniffLength)]
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	contentType := http.DetectContentType(head)
+	return !strings.HasPrefix(contentType, "text/") && contentType != "application/json"
+}
+
+// SkippedSummary returns a one-line summary of the skipped files by reason,
+// e.g. `3 files (2 binary, 1 generated)`.
+func SkippedSummary(skipped []Skipped) string {
+	if len(skipped) == 0 {
+		return "0 files"
+	}
+	counts := map[string]int{}
+	for _, s := range skipped {
+		counts[s.Reason]++
+	}
+	reasons := make([]string, 0, len(counts))
+	for reason := range counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%d %s", counts[reason], reason)
+	}
+	noun := "files"
+	if len(skipped) == 1 {
+		noun = "file"
+	}
+	return fmt.Sprintf("%d %s (%s)", len(skipped), noun, strings.Join(parts, ", "))
+}
@@ -0,0 +1,30 @@
+package codebase
+
+import "io/fs"
+
+// numReaders is the number of files read concurrently by Scan.
+const numReaders int = 8
+
+// readJob is a file found by the walker that must be read.
+type readJob struct {
+	// index is the position of the file in walk order.
+	index int
+	path  string
+	info  fs.FileInfo
+}
+
+// readResult is the content of a file read by a reader.
+type readResult struct {
+	index int
+	entry cacheEntry
+	err   error
+}
+
+// scanItem is a file found by the walker, either skipped before reading or read by a reader.
+type scanItem struct {
+	path  string
+	name  string
+	skip  *Skipped
+	entry cacheEntry
+	err   error
+}
@@ -11,6 +11,10 @@ const (
 	FILE_IGNORE string = ".hzmignore"
 	// FILE_LOG is the log file name.
 	FILE_LOG string = "hzmind.log"
+	// FILE_POLICY is the file name of the project's command policy.
+	FILE_POLICY string = "policy.json"
+	// FILE_SECRETS_ALLOWLIST is the file name of the allowlist of the secret scanner.
+	FILE_SECRETS_ALLOWLIST string = ".hzmsecrets"
 )
 
 const (
@@ -18,6 +22,10 @@ const (
 	DIR_MAIN string = "hzmind"
 	// DIR_OUT is the output directory name.
 	DIR_OUT string = "out"
+	// DIR_SESSIONS is the directory name of the saved chat sessions.
+	DIR_SESSIONS string = "sessions"
+	// DIR_SNAPSHOTS is the directory name of the working-tree snapshots.
+	DIR_SNAPSHOTS string = "snapshots"
 )
 
 // PATH_DIR_BINARY_DATA is the full path to the binary data directory.
@@ -32,8 +40,16 @@ var (
 	PATH_FILE_IGNORE string = filepath.Join(DIR_MAIN, FILE_IGNORE)
 	// PATH_FILE_LOG is the full path to the log file.
 	PATH_FILE_LOG string = filepath.Join(DIR_MAIN, FILE_LOG)
+	// PATH_FILE_POLICY is the full path to the project's command policy.
+	PATH_FILE_POLICY string = filepath.Join(DIR_MAIN, FILE_POLICY)
+	// PATH_FILE_SECRETS_ALLOWLIST is the full path to the allowlist of the secret scanner.
+	PATH_FILE_SECRETS_ALLOWLIST string = filepath.Join(DIR_MAIN, FILE_SECRETS_ALLOWLIST)
 	// PATH_DIR_OUT is the full path to the output directory.
 	PATH_DIR_OUT string = filepath.Join(DIR_MAIN, DIR_OUT)
+	// PATH_DIR_SESSIONS is the full path to the sessions directory.
+	PATH_DIR_SESSIONS string = filepath.Join(DIR_MAIN, DIR_SESSIONS)
+	// PATH_DIR_SNAPSHOTS is the full path to the snapshots directory.
+	PATH_DIR_SNAPSHOTS string = filepath.Join(DIR_MAIN, DIR_SNAPSHOTS)
 )
 
 // TITLE is the ASCII art title for the HarzMind Code REPL.
@@ -3,12 +3,22 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 
 	"github.com/thxrsxm/harzmind-code/internal/acc"
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/codebase"
+	"github.com/thxrsxm/harzmind-code/internal/executor"
+	"github.com/thxrsxm/harzmind-code/internal/llmx"
+	"github.com/thxrsxm/harzmind-code/internal/modelinfo"
+	"github.com/thxrsxm/harzmind-code/internal/policy"
 )
 
+// DEFAULT_CURRENCY is the currency of the built-in model prices.
+const DEFAULT_CURRENCY string = "USD"
+
 // Config represents the application's persistent configuration.
 // It wraps a configData struct and provides methods to load, save, and mutate configuration.
 // Fields are unexported to enforce controlled access via methods.
@@ -20,7 +30,19 @@ type Config struct {
 // configData holds the serialized configuration structure.
 // It is designed for JSON marshaling/unmarshaling and decoupled from runtime state.
 type configData struct {
-	AccountManager acc.AccountManager `json:"accountManagement"`
+	AccountManager acc.AccountManager    `json:"accountManagement"`
+	Retry          api.RetryPolicy       `json:"retry"`
+	Compaction     llmx.CompactionPolicy `json:"compaction"`
+	Agent          llmx.AgentPolicy      `json:"agent"`
+	// Commands is the global policy for shell commands, merged with the project's policy.
+	Commands policy.Policy `json:"commands"`
+	// Sandbox controls the execution of shell commands.
+	Sandbox executor.Sandbox `json:"sandbox"`
+	// Codebase limits the files sent to the model.
+	Codebase codebase.Limits `json:"codebase"`
+	// Prices extends or overrides the built-in model prices (per million tokens).
+	Prices   map[string]modelinfo.Price `json:"prices,omitempty"`
+	Currency string                     `json:"currency,omitempty"`
 }
 
 // NewConfig creates a new configuration file at the given path with default/empty state.
@@ -57,6 +79,13 @@ func NewConfig(path string) (*Config, error) {
 func newConfigData(config *Config) *configData {
 	return &configData{
 		AccountManager: *acc.NewAccountManager(func() error { return config.SaveConfig() }),
+		Retry:          api.DefaultRetryPolicy(),
+		Compaction:     llmx.DefaultCompactionPolicy(),
+		Agent:          llmx.DefaultAgentPolicy(),
+		Commands:       policy.Policy{Default: policy.ASK, Rules: []policy.Rule{}},
+		Sandbox:        executor.DefaultSandbox(),
+		Codebase:       codebase.DefaultLimits(),
+		Currency:       DEFAULT_CURRENCY,
 	}
 }
 
@@ -85,6 +114,7 @@ func (c *Config) SaveConfig() error {
 
 // LoadConfig reads and deserializes a configuration from the specified file path.
 // It validates the JSON structure and initializes the AccountManager with a save hook.
+// Settings missing in the file keep their defaults (see newConfigData).
 // If the file is missing, unreadable, or contains invalid JSON, an error is returned.
 func LoadConfig(path string) (*Config, error) {
 	// Open the configuration file
@@ -98,22 +128,62 @@ func LoadConfig(path string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	// Unmarshal the JSON content into a Config struct
-	var data configData
-	err = json.Unmarshal(byteValue, &data)
+	config := &Config{
+		path: path,
+	}
+	// Unmarshal the JSON content over the defaults, so that settings missing in older config files keep them
+	config.data = newConfigData(config)
+	err = json.Unmarshal(byteValue, config.data)
 	if err != nil {
 		return nil, err
 	}
-	config := Config{
-		path: path,
-		data: &data,
+	if err := config.data.Commands.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid command policy: %w", err)
 	}
-	// Re-hook the AccountManager's save callback to this config's SaveConfig method.
-	data.AccountManager.SetSave(func() error { return config.SaveConfig() })
-	return &config, nil
+	return config, nil
 }
 
 // GetAccountManager returns the underlying AccountManager instance.
 func (c *Config) GetAccountManager() *acc.AccountManager {
 	return &c.data.AccountManager
 }
+
+// GetRetryPolicy returns the configured policy for retrying failed API requests.
+func (c *Config) GetRetryPolicy() api.RetryPolicy {
+	return c.data.Retry
+}
+
+// GetCompactionPolicy returns the configured policy for summarizing long conversations.
+func (c *Config) GetCompactionPolicy() llmx.CompactionPolicy {
+	return c.data.Compaction
+}
+
+// GetAgentPolicy returns the configured policy for agent mode.
+func (c *Config) GetAgentPolicy() llmx.AgentPolicy {
+	return c.data.Agent
+}
+
+// GetCommandPolicy returns the global policy for shell commands.
+func (c *Config) GetCommandPolicy() policy.Policy {
+	return c.data.Commands
+}
+
+// GetSandbox returns the configured sandbox for shell commands.
+func (c *Config) GetSandbox() executor.Sandbox {
+	return c.data.Sandbox
+}
+
+// GetCodebaseLimits returns the limits of the files sent to the model.
+func (c *Config) GetCodebaseLimits() codebase.Limits {
+	return c.data.Codebase
+}
+
+// GetPrices returns the model prices configured by the user.
+func (c *Config) GetPrices() map[string]modelinfo.Price {
+	return c.data.Prices
+}
+
+// GetCurrency returns the currency in which the model prices are given.
+func (c *Config) GetCurrency() string {
+	return c.data.Currency
+}
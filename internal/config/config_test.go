@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thxrsxm/harzmind-code/internal/acc"
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/executor"
+)
+
+func TestLoadConfigDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"retry": {"maxAttempts": 5}, "sandbox": {"timeoutSec": 0}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := api.DefaultRetryPolicy()
+	want.MaxAttempts = 5
+	if got := config.GetRetryPolicy(); got != want {
+		t.Errorf("GetRetryPolicy() = %+v, want %+v", got, want)
+	}
+	wantSandbox := executor.DefaultSandbox()
+	wantSandbox.TimeoutSec = 0
+	if got := config.GetSandbox(); got != wantSandbox {
+		t.Errorf("GetSandbox() = %+v, want %+v", got, wantSandbox)
+	}
+	if got := config.GetCurrency(); got != DEFAULT_CURRENCY {
+		t.Errorf("GetCurrency() = %q, want %q", got, DEFAULT_CURRENCY)
+	}
+	// Changes of the accounts are saved to the file
+	if err := config.GetAccountManager().AddAccount(acc.Account{Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	config, err = LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.GetAccountManager().GetAccount("test"); err != nil {
+		t.Errorf("after AddAccount() and LoadConfig(): %v", err)
+	}
+}
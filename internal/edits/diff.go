@@ -0,0 +1,209 @@
+package edits
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// contextLines is the number of unchanged lines shown around a change.
+	contextLines int = 3
+	// maxDiffCells limits the size of the LCS table, larger changes are shown as a full replacement.
+	maxDiffCells int = 4_000_000
+)
+
+// opKind is the kind of a line in a diff.
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+// diffLine is a line of a diff with its line numbers in the old and the new text.
+type diffLine struct {
+	kind   opKind
+	text   string
+	oldNum int
+	newNum int
+}
+
+// Diff returns the unified diff between the old and the new content of a file.
+// An empty string means that the contents are equal.
+func Diff(path, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	lines := diffLines(splitLines(oldText), splitLines(newText))
+	var sb strings.Builder
+	oldName := "a/" + path
+	if len(oldText) == 0 {
+		oldName = "/dev/null"
+	}
+	fmt.Fprintf(&sb, "--- %s\n+++ b/%s\n", oldName, path)
+	// Group the changes into hunks with context
+	for start := 0; start < len(lines); {
+		// Find the next change
+		first := start
+		for first < len(lines) && lines[first].kind == opEqual {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		// Extend the hunk while changes are close to each other
+		from := max(first-contextLines, start)
+		to := first
+		for i := first; i < len(lines); i++ {
+			if lines[i].kind != opEqual {
+				to = i
+			} else if i-to > 2*contextLines {
+				break
+			}
+		}
+		to = min(to+contextLines+1, len(lines))
+		writeHunk(&sb, lines[from:to])
+		start = to
+	}
+	return sb.String()
+}
+
+// CountChanges returns the number of added and removed lines between the old and the new text.
+func CountChanges(oldText, newText string) (int, int) {
+	added, removed := 0, 0
+	for _, l := range diffLines(splitLines(oldText), splitLines(newText)) {
+		switch l.kind {
+		case opInsert:
+			added++
+		case opDelete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// writeHunk writes a hunk with its `@@ -a,b +c,d @@` header.
+func writeHunk(sb *strings.Builder, lines []diffLine) {
+	oldStart, newStart := lines[0].oldNum, lines[0].newNum
+	oldCount, newCount := 0, 0
+	for _, l := range lines {
+		if l.kind != opInsert {
+			oldCount++
+		}
+		if l.kind != opDelete {
+			newCount++
+		}
+	}
+	// Empty ranges start at the line before
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+	for _, l := range lines {
+		sb.WriteByte(byte(l.kind))
+		sb.WriteString(l.text)
+		sb.WriteByte('\n')
+	}
+}
+
+// diffLines computes the line diff of a and b using the longest common subsequence.
+// Common prefixes and suffixes are skipped before building the LCS table.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	kinds := []opKind{}
+	for range prefix {
+		kinds = append(kinds, opEqual)
+	}
+	kinds = append(kinds, diffMiddle(midA, midB)...)
+	for range suffix {
+		kinds = append(kinds, opEqual)
+	}
+	// Assign the texts and line numbers
+	lines := make([]diffLine, 0, len(kinds))
+	i, j := 0, 0
+	for _, k := range kinds {
+		switch k {
+		case opEqual:
+			lines = append(lines, diffLine{kind: k, text: a[i], oldNum: i + 1, newNum: j + 1})
+			i++
+			j++
+		case opDelete:
+			lines = append(lines, diffLine{kind: k, text: a[i], oldNum: i + 1, newNum: j + 1})
+			i++
+		case opInsert:
+			lines = append(lines, diffLine{kind: k, text: b[j], oldNum: i + 1, newNum: j + 1})
+			j++
+		}
+	}
+	return lines
+}
+
+// diffMiddle returns the edit operations transforming a into b.
+func diffMiddle(a, b []string) []opKind {
+	kinds := []opKind{}
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		// Too large for the LCS table, replace all lines
+		for range a {
+			kinds = append(kinds, opDelete)
+		}
+		for range b {
+			kinds = append(kinds, opInsert)
+		}
+		return kinds
+	}
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			kinds = append(kinds, opEqual)
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			kinds = append(kinds, opDelete)
+			i++
+		} else {
+			kinds = append(kinds, opInsert)
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		kinds = append(kinds, opDelete)
+	}
+	for ; j < len(b); j++ {
+		kinds = append(kinds, opInsert)
+	}
+	return kinds
+}
+
+// splitLines splits a text into lines without line endings.
+func splitLines(text string) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,248 @@
+// Package edits parses file edits proposed by the model from its replies,
+// previews them as colored unified diffs and applies them after confirmation.
+//
+// Edits are fenced code blocks whose info string carries the file path,
+// e.g. "```go path=internal/app/app.go". A block either contains the complete
+// new content of the file or one or more SEARCH/REPLACE blocks.
+package edits
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/thxrsxm/harzmind-code/internal/codebase"
+	"github.com/thxrsxm/harzmind-code/internal/common"
+	"github.com/thxrsxm/harzmind-code/internal/secrets"
+)
+
+// Markers of a SEARCH/REPLACE block.
+const (
+	markerSearch  string = "<<<<<<< SEARCH"
+	markerDivider string = "======="
+	markerReplace string = ">>>>>>> REPLACE"
+)
+
+// Instructions describes the edit protocol to the model. It is added to the system prompt.
+const Instructions string = `## Editing files
+
+To change a file, reply with a fenced code block whose info string contains the path of the file
+relative to the project root, e.g. ` + "```go path=internal/app/app.go" + `.
+The block contains either the complete new content of the file or one or more blocks of the form:
+
+` + markerSearch + `
+exact lines of the current file
+` + markerDivider + `
+replacement lines
+` + markerReplace + `
+
+Every SEARCH text must match the current file exactly and only once. Use separate code blocks per file.
+If the content contains fenced code blocks itself, open and close the block with a longer fence, e.g. ` + "````" + `.`
+
+// fencePattern matches the opening line of a path-tagged code fence.
+var fencePattern *regexp.Regexp = regexp.MustCompile("^(`{3,}|~{3,})\\S*.*\\bpath=(\"[^\"]+\"|\\S+)")
+
+// Replacement replaces the Search text of a file by the Replace text.
+type Replacement struct {
+	Search  string
+	Replace string
+}
+
+// Edit is a change of a single file proposed by the model.
+// Either Content (the complete new file) or Replacements is set.
+type Edit struct {
+	Path         string
+	Content      string
+	Replacements []Replacement
+}
+
+// Parse extracts all path-tagged code blocks from the reply.
+// Blocks without a closing fence are ignored.
+//
+// As in CommonMark, a block is closed by a fence of the same character that is at least
+// as long as the opening fence. Nested blocks opened with an info string (e.g. "```go")
+// inside the content are skipped up to their own closing fence.
+func Parse(reply string) []Edit {
+	edits := []Edit{}
+	lines := strings.Split(strings.ReplaceAll(reply, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		m := fencePattern.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		fence := m[1]
+		path := strings.Trim(m[2], "\"")
+		// Find the closing fence, skipping nested blocks
+		end := -1
+		nested := []int{}
+		for j := i + 1; j < len(lines) && end < 0; j++ {
+			length, info := parseFence(lines[j], fence[0])
+			switch {
+			case length == 0:
+			case info:
+				nested = append(nested, length)
+			case len(nested) > 0 && length >= nested[len(nested)-1]:
+				nested = nested[:len(nested)-1]
+			case length >= len(fence):
+				end = j
+			}
+		}
+		if end < 0 {
+			break
+		}
+		body := lines[i+1 : end]
+		edit := Edit{Path: path}
+		if replacements, ok := parseReplacements(body); ok {
+			edit.Replacements = replacements
+		} else {
+			edit.Content = strings.Join(body, "\n") + "\n"
+		}
+		edits = append(edits, edit)
+		i = end
+	}
+	return edits
+}
+
+// parseFence returns the length of the fence of the character c that the line starts with
+// (0 if there is none) and whether an info string follows the fence.
+func parseFence(line string, c byte) (int, bool) {
+	line = strings.TrimSpace(line)
+	length := 0
+	for length < len(line) && line[length] == c {
+		length++
+	}
+	if length < 3 {
+		return 0, false
+	}
+	return length, length < len(line)
+}
+
+// parseReplacements parses the SEARCH/REPLACE blocks of a code block.
+// It reports false if the code block contains none.
+func parseReplacements(lines []string) ([]Replacement, bool) {
+	replacements := []Replacement{}
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != markerSearch {
+			continue
+		}
+		divider, end := -1, -1
+		for j := i + 1; j < len(lines); j++ {
+			line := strings.TrimSpace(lines[j])
+			if line == markerDivider && divider < 0 {
+				divider = j
+			} else if line == markerReplace && divider >= 0 {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			break
+		}
+		replacements = append(replacements, Replacement{
+			Search:  joinLines(lines[i+1 : divider]),
+			Replace: joinLines(lines[divider+1 : end]),
+		})
+		i = end
+	}
+	return replacements, len(replacements) > 0
+}
+
+// joinLines joins lines with a trailing newline (none for no lines).
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Apply returns the new content of the file given its current content.
+func (e Edit) Apply(current string) (string, error) {
+	if len(e.Replacements) == 0 {
+		return e.Content, nil
+	}
+	result := current
+	for _, r := range e.Replacements {
+		switch strings.Count(result, r.Search) {
+		case 0:
+			return "", fmt.Errorf("search text not found in %s:\n%s", e.Path, r.Search)
+		case 1:
+			result = strings.Replace(result, r.Search, r.Replace, 1)
+		default:
+			return "", fmt.Errorf("search text is ambiguous in %s:\n%s", e.Path, r.Search)
+		}
+	}
+	return result, nil
+}
+
+// ValidatePath checks that a path proposed by the model stays inside the project root
+// and is not excluded by the ignore patterns or a sensitive file (see secrets.IsSensitiveFile).
+// It returns the cleaned relative path.
+func ValidatePath(path string) (string, error) {
+	if len(path) == 0 {
+		return "", fmt.Errorf("empty path")
+	}
+	if filepath.IsAbs(path) || filepath.VolumeName(path) != "" || strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("path %s is absolute", path)
+	}
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is outside of the project", path)
+	}
+	if codebase.IsIgnored(clean) {
+		return "", fmt.Errorf("path %s is ignored", path)
+	}
+	if allow, _ := secrets.LoadAllowlist(common.PATH_FILE_SECRETS_ALLOWLIST); allow.Blocked(clean) {
+		return "", fmt.Errorf("path %s is a sensitive file", path)
+	}
+	// Symbolic links must not lead out of the project
+	inside, err := insideRoot(clean)
+	if err != nil {
+		return "", err
+	}
+	if !inside {
+		return "", fmt.Errorf("path %s is outside of the project", path)
+	}
+	return clean, nil
+}
+
+// insideRoot reports whether the path resolves to a location inside the working directory.
+// Symbolic links of the longest existing part of the path are evaluated.
+func insideRoot(path string) (bool, error) {
+	root, err := os.Getwd()
+	if err != nil {
+		return false, err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return false, err
+	}
+	// Find the longest existing part of the path
+	existing, rest := path, ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		rest = filepath.Join(filepath.Base(existing), rest)
+		if parent == existing || parent == "." {
+			existing = "."
+			break
+		}
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return false, err
+	}
+	resolved, err = filepath.Abs(filepath.Join(resolved, rest))
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil {
+		return false, nil
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
@@ -0,0 +1,129 @@
+package edits
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const reply = "Here are the changes:\n\n" +
+	"```go path=main.go\n" +
+	"package main\n\nfunc main() {}\n" +
+	"```\n\n" +
+	"```go path=internal/app/app.go\n" +
+	"<<<<<<< SEARCH\n" +
+	"\told()\n" +
+	"=======\n" +
+	"\tnew()\n" +
+	">>>>>>> REPLACE\n" +
+	"```\n\n" +
+	"```go\nfmt.Println(\"untagged\")\n```\n"
+
+func TestParse(t *testing.T) {
+	edits := Parse(reply)
+	if len(edits) != 2 {
+		t.Fatalf("Parse() returned %d edits, want 2", len(edits))
+	}
+	if edits[0].Path != "main.go" || edits[0].Content != "package main\n\nfunc main() {}\n" {
+		t.Errorf("whole-file edit = %+v", edits[0])
+	}
+	want := Replacement{Search: "\told()\n", Replace: "\tnew()\n"}
+	if edits[1].Path != "internal/app/app.go" || len(edits[1].Replacements) != 1 || edits[1].Replacements[0] != want {
+		t.Errorf("search/replace edit = %+v", edits[1])
+	}
+}
+
+func TestParseNestedFences(t *testing.T) {
+	readme := "# Usage\n\n```sh\ngo run .\n```\n\nDone.\n"
+	tests := []struct {
+		name  string
+		reply string
+		want  string
+	}{
+		{"Nested block", "```markdown path=README.md\n" + readme + "```\n", readme},
+		{"Longer fence", "````markdown path=README.md\n" + readme + "````\n", readme},
+		{"Shorter fence in longer fence", "````markdown path=README.md\n" + readme + "```\n````\n", readme + "```\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edits := Parse(tt.reply)
+			if len(edits) != 1 {
+				t.Fatalf("Parse() returned %d edits, want 1", len(edits))
+			}
+			if edits[0].Content != tt.want {
+				t.Errorf("Content = %q, want %q", edits[0].Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	edit := Edit{Path: "a.go", Replacements: []Replacement{{Search: "b\n", Replace: "B\n"}}}
+	tests := []struct {
+		name    string
+		current string
+		want    string
+		wantErr bool
+	}{
+		{"Replace", "a\nb\nc\n", "a\nB\nc\n", false},
+		{"Not found", "a\nc\n", "", true},
+		{"Ambiguous", "b\nb\n", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := edit.Apply(tt.current)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Apply(%q) = %q, %v, want %q", tt.current, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	oldText := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	newText := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\n"
+	want := "--- a/x.txt\n+++ b/x.txt\n" +
+		"@@ -2,9 +2,10 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n i\n j\n+k\n"
+	if got := Diff("x.txt", oldText, newText); got != want {
+		t.Errorf("Diff() =\n%s\nwant\n%s", got, want)
+	}
+	if got := Diff("x.txt", oldText, oldText); got != "" {
+		t.Errorf("Diff() of equal texts = %q", got)
+	}
+	want = "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,1 @@\n+x\n"
+	if got := Diff("new.txt", "", "x\n"); got != want {
+		t.Errorf("Diff() of a new file =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.Symlink(os.TempDir(), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"Relative path", "internal/app/app.go", false},
+		{"Absolute path", "/etc/passwd", true},
+		{"Parent directory", "../secret.txt", true},
+		{"Cleaned parent directory", "internal/../../secret.txt", true},
+		{"Ignored path", ".git/config", true},
+		{"Project directory", "hzmind/HZMIND.md", true},
+		{"Symbolic link", "link/file.txt", true},
+		{"Sensitive file", "config/.env", true},
+		{"Env template", ".env.example", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidatePath(tt.path); (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePath(%s) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
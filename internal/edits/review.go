@@ -0,0 +1,120 @@
+package edits
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thxrsxm/harzmind-code/internal/input"
+	"github.com/thxrsxm/harzmind-code/internal/logger"
+	"github.com/thxrsxm/harzmind-code/internal/output"
+	"github.com/thxrsxm/rnbw"
+)
+
+// Review shows the diff of every edit proposed in the reply and applies it on confirmation (see ReviewEdits).
+func Review(reply string) ([]string, error) {
+	return ReviewEdits(Parse(reply))
+}
+
+// ReviewEdits shows the diff of every edit and applies it on confirmation.
+// The user answers `y` (apply), `n` (skip) or `a` (apply this and all remaining edits).
+// Invalid edits (e.g. a path outside of the project or a search text that does not match)
+// are reported and skipped. It returns the paths of the applied edits.
+func ReviewEdits(edits []Edit) ([]string, error) {
+	applied := []string{}
+	all := false
+	for _, edit := range edits {
+		path, current, updated, err := prepare(edit)
+		if err != nil {
+			output.PrintfWarning("skipping edit: %v\n", err)
+			logger.Log(logger.WARNING, "skipping edit: %v", err)
+			continue
+		}
+		diff := Diff(filepath.ToSlash(path), current, updated)
+		if len(diff) == 0 {
+			output.Printf("No changes to %s\n", path)
+			continue
+		}
+		output.Println()
+		printDiff(diff)
+		// Ask for confirmation
+		if !all {
+			output.Printf("Apply changes to %s? [y/n/a] ", path)
+			answer, err := input.ReadInput(true)
+			if err != nil {
+				return applied, err
+			}
+			switch strings.ToLower(answer) {
+			case "y", "yes":
+			case "a", "all":
+				all = true
+			default:
+				output.Printf("Skipped %s\n", path)
+				continue
+			}
+		}
+		if err := write(path, updated); err != nil {
+			return applied, err
+		}
+		applied = append(applied, path)
+		rnbw.ForegroundColor(rnbw.Green)
+		output.Printf("Applied changes to %s\n", path)
+		rnbw.ResetColor()
+		logger.Log(logger.INFO, "applied edit to '%s'", path)
+	}
+	return applied, nil
+}
+
+// prepare validates the path of the edit and computes the current and the new content of the file.
+func prepare(edit Edit) (string, string, string, error) {
+	path, err := ValidatePath(edit.Path)
+	if err != nil {
+		return "", "", "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !(errors.Is(err, fs.ErrNotExist) && len(edit.Replacements) == 0) {
+		return "", "", "", err
+	}
+	updated, err := edit.Apply(string(data))
+	if err != nil {
+		return "", "", "", err
+	}
+	return path, string(data), updated, nil
+}
+
+// write writes the content to the file, creating missing directories.
+// The permissions of an existing file are kept.
+func write(path, content string) error {
+	perm := fs.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// printDiff prints a unified diff with removed lines in red and added lines in green.
+func printDiff(diff string) {
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			rnbw.ForegroundColor(rnbw.Gray)
+		case strings.HasPrefix(line, "@@"):
+			rnbw.ForegroundColor(rnbw.Cyan)
+		case strings.HasPrefix(line, "-"):
+			rnbw.ForegroundColor(rnbw.Red)
+		case strings.HasPrefix(line, "+"):
+			rnbw.ForegroundColor(rnbw.Green)
+		}
+		output.Print(line)
+		rnbw.ResetColor()
+	}
+}
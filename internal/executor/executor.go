@@ -1,23 +1,27 @@
 // Package executor provides utilities for executing external commands,
-// including running bash scripts and opening files in terminal-based editors.
+// including running bash scripts (optionally sandboxed, see Run) and opening files in terminal-based editors.
 package executor
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"runtime"
+	"strings"
 )
 
-// ExecuteBash executes a bash command and returns the output and error.
-func ExecuteBash(command string) (string, error) {
-	if len(command) == 0 {
-		return "", nil
-	}
-	// Create a new bash command with the given command string
-	cmd := exec.Command("bash", "-c", command)
-	// Run the command and capture the output
-	output, err := cmd.CombinedOutput()
-	return string(output), err
+// ExitCode returns the exit code of a command from the error of its execution:
+// 0 without error and -1 if the command did not exit normally.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
 }
 
 // OpenEditor opens a file in the specified editor.
@@ -38,3 +42,48 @@ func OpenEditor(editor, fileName string) error {
 	}
 	return nil
 }
+
+// EditText opens the text in the user's editor and returns the edited text.
+// The editor is taken from $VISUAL or $EDITOR and may contain arguments (e.g. `code --wait`).
+// Without either, vi (notepad on Windows) is used.
+func EditText(text string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if len(editor) == 0 {
+		editor = os.Getenv("EDITOR")
+	}
+	if len(editor) == 0 {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	// Write the text to a temporary file
+	file, err := os.CreateTemp("", "hzmind-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(text)
+	file.Close()
+	if err != nil {
+		return "", err
+	}
+	// Open the file in the editor
+	args := strings.Fields(editor)
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return "", fmt.Errorf("editor '%s' not found: %w", args[0], err)
+	}
+	cmd := exec.Command(args[0], append(args[1:], file.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	// Read the edited text
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,47 @@
+//go:build linux
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// isolation returns the bubblewrap command line that runs a command isolated in the project root.
+// The root file system is mounted read-only, the project directory and a fresh /tmp are writable,
+// the hidden paths are replaced by empty directories and all namespaces (except the network
+// if requested) are unshared. It returns false if bubblewrap is not installed.
+func isolation(root, dir string, opts Options) ([]string, bool) {
+	bwrap, err := exec.LookPath("bwrap")
+	if err != nil {
+		return nil, false
+	}
+	args := []string{bwrap,
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+		"--bind", root, root,
+		"--unshare-all",
+		"--die-with-parent",
+	}
+	if opts.Network {
+		args = append(args, "--share-net")
+	}
+	for _, path := range opts.Hidden {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		// Never hide the project itself
+		if rel, err := filepath.Rel(abs, root); err == nil && !strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if info, err := os.Stat(abs); err == nil && info.IsDir() {
+			args = append(args, "--tmpfs", abs)
+		}
+	}
+	return append(args, "--chdir", dir), true
+}
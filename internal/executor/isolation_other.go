@@ -0,0 +1,8 @@
+//go:build !linux
+
+package executor
+
+// isolation is only supported on Linux.
+func isolation(root, dir string, opts Options) ([]string, bool) {
+	return nil, false
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package executor
+
+import "os/exec"
+
+// setProcessGroup is only supported on Unix, elsewhere the command itself is killed on cancellation.
+func setProcessGroup(cmd *exec.Cmd) {}
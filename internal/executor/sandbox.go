@@ -0,0 +1,263 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Sandbox configures the execution of commands run with /bash or by the model.
+type Sandbox struct {
+	// TimeoutSec is the time in seconds after which a command is killed (0 disables the timeout).
+	TimeoutSec int `json:"timeoutSec"`
+	// BashTimeoutSec is the timeout of the commands run by the user with /bash (0 disables the timeout).
+	BashTimeoutSec int `json:"bashTimeoutSec"`
+	// MaxOutputBytes is the maximum size of the captured output (0 disables the limit).
+	MaxOutputBytes int `json:"maxOutputBytes"`
+	// ScrubEnv removes API keys, tokens and passwords from the environment of the command.
+	ScrubEnv bool `json:"scrubEnv"`
+	// Isolate runs the command with bubblewrap (Linux only): the file system is read-only
+	// except for the project directory and /tmp, and the application's data directory is hidden.
+	Isolate bool `json:"isolate"`
+	// Network keeps network access in an isolated command.
+	Network bool `json:"network"`
+}
+
+// DefaultSandbox returns the sandbox configuration used when none is configured.
+func DefaultSandbox() Sandbox {
+	return Sandbox{
+		TimeoutSec:     120,
+		BashTimeoutSec: 0,
+		MaxOutputBytes: 64 * 1024,
+		ScrubEnv:       true,
+		Isolate:        false,
+		Network:        true,
+	}
+}
+
+// Options returns the execution options of the sandbox configuration.
+// The hidden paths (e.g. the directory of the configuration) are not accessible to isolated commands.
+func (s Sandbox) Options(hidden ...string) Options {
+	return Options{
+		Timeout:   time.Duration(s.TimeoutSec) * time.Second,
+		MaxOutput: s.MaxOutputBytes,
+		ScrubEnv:  s.ScrubEnv,
+		Isolate:   s.Isolate,
+		Network:   s.Network,
+		Hidden:    hidden,
+	}
+}
+
+// BashOptions returns the execution options of the commands run by the user with /bash,
+// which have their own timeout (see BashTimeoutSec).
+func (s Sandbox) BashOptions(hidden ...string) Options {
+	opts := s.Options(hidden...)
+	opts.Timeout = time.Duration(s.BashTimeoutSec) * time.Second
+	return opts
+}
+
+// Options controls the execution of a command by Run.
+type Options struct {
+	// Timeout kills the command after the duration (0 disables the timeout).
+	Timeout time.Duration
+	// MaxOutput is the maximum number of captured output bytes (0 disables the limit).
+	MaxOutput int
+	// Dir is the working directory, it must be inside the project directory (empty is the project root).
+	Dir string
+	// ScrubEnv removes secrets from the environment (see scrubEnv).
+	ScrubEnv bool
+	// Isolate runs the command with bubblewrap if it is available.
+	Isolate bool
+	// Network keeps network access in an isolated command.
+	Network bool
+	// Hidden are paths replaced by empty directories in an isolated command.
+	Hidden []string
+	// Stream receives the complete output while the command is running (optional).
+	Stream io.Writer
+}
+
+// Result is the outcome of a command executed by Run.
+type Result struct {
+	// Output is the combined stdout and stderr, at most Options.MaxOutput bytes.
+	Output string
+	// ExitCode is the exit code of the command, -1 if it was killed.
+	ExitCode int
+	// Truncated is true if the output exceeded Options.MaxOutput.
+	Truncated bool
+	// TimedOut is true if the command was killed after Options.Timeout.
+	TimedOut bool
+	// Isolated is true if the command ran isolated.
+	Isolated bool
+	// Isolate is true if isolation was requested.
+	Isolate bool
+	// Timeout is the configured timeout.
+	Timeout time.Duration
+	// MaxOutput is the configured output limit.
+	MaxOutput int
+}
+
+// Status describes how the command ended: `exit status N`, a timeout or a kill.
+func (r Result) Status() string {
+	switch {
+	case r.TimedOut:
+		return fmt.Sprintf("timed out after %s", r.Timeout)
+	case r.ExitCode < 0:
+		return "killed"
+	default:
+		return fmt.Sprintf("exit status %d", r.ExitCode)
+	}
+}
+
+// Report describes the status, the truncation and missing isolation of the command, one note per line.
+func (r Result) Report() string {
+	notes := []string{r.Status()}
+	if r.Truncated {
+		notes = append(notes, fmt.Sprintf("output truncated to %d bytes", r.MaxOutput))
+	}
+	if r.Isolate && !r.Isolated {
+		notes = append(notes, "not isolated, bubblewrap is not available")
+	}
+	return strings.Join(notes, "\n")
+}
+
+// Run executes a bash command with the options and returns its result.
+// A failing command is reported by the exit code of the result, the error is only set
+// if the command could not be started or the working directory is outside of the project.
+// The command runs in its own process group, which is killed when ctx is cancelled
+// (e.g. by Ctrl+C) or the timeout expires.
+func Run(ctx context.Context, command string, opts Options) (Result, error) {
+	result := Result{Timeout: opts.Timeout, MaxOutput: opts.MaxOutput, Isolate: opts.Isolate}
+	root, err := os.Getwd()
+	if err != nil {
+		return result, err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return result, err
+	}
+	dir, err := projectDir(root, opts.Dir)
+	if err != nil {
+		return result, err
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	name, args := "bash", []string{"-c", command}
+	if opts.Isolate {
+		if bwrap, ok := isolation(root, dir, opts); ok {
+			name, args = bwrap[0], append(bwrap[1:], name, "-c", command)
+			result.Isolated = true
+		}
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.WaitDelay = time.Second
+	setProcessGroup(cmd)
+	if opts.ScrubEnv {
+		cmd.Env = scrubEnv(os.Environ())
+	}
+	out := &limitedBuffer{max: opts.MaxOutput}
+	var w io.Writer = out
+	if opts.Stream != nil {
+		w = io.MultiWriter(out, opts.Stream)
+	}
+	// The same writer for both streams keeps the order of the output
+	cmd.Stdout = w
+	cmd.Stderr = w
+	err = cmd.Run()
+	result.Output = out.String()
+	result.Truncated = out.truncated
+	result.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	result.ExitCode = ExitCode(err)
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && ctx.Err() == nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// projectDir resolves the working directory and checks that it is inside the (resolved) project root.
+func projectDir(root, dir string) (string, error) {
+	if len(dir) == 0 {
+		return root, nil
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("directory %s is outside of the project", dir)
+	}
+	return resolved, nil
+}
+
+// sensitiveSegments are parts of the names of environment variables that hold secrets,
+// matched as whole segments between underscores (e.g. GITHUB_TOKEN, DB_PASS).
+var sensitiveSegments []string = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "PASS", "CREDENTIAL", "CREDENTIALS", "COOKIE", "APIKEY"}
+
+// sensitiveSuffixes are endings of the names of environment variables that hold secrets.
+// KEY and AUTH only count at the end, they are also part of names such as SSH_AUTH_SOCK or KEYTIMEOUT.
+var sensitiveSuffixes []string = []string{"_KEY", "_AUTH", "TOKEN", "SECRET", "PASSWORD", "APIKEY"}
+
+// scrubEnv returns the environment without variables whose names indicate a secret.
+func scrubEnv(env []string) []string {
+	scrubbed := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if !sensitiveName(strings.ToUpper(name)) {
+			scrubbed = append(scrubbed, kv)
+		}
+	}
+	return scrubbed
+}
+
+// sensitiveName reports whether the upper-case name of an environment variable indicates a secret.
+func sensitiveName(name string) bool {
+	for _, segment := range strings.Split(name, "_") {
+		if slices.Contains(sensitiveSegments, segment) {
+			return true
+		}
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedBuffer captures up to max bytes (all if max is 0) and discards the rest.
+type limitedBuffer struct {
+	sb        strings.Builder
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if b.max > 0 {
+		free := b.max - b.sb.Len()
+		if free < len(p) {
+			p = p[:max(free, 0)]
+			b.truncated = true
+		}
+	}
+	b.sb.Write(p)
+	return n, nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.sb.String()
+}
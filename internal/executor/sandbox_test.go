@@ -0,0 +1,107 @@
+package executor
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not available")
+	}
+	t.Chdir(t.TempDir())
+	tests := []struct {
+		name          string
+		command       string
+		opts          Options
+		wantOutput    string
+		wantExitCode  int
+		wantTruncated bool
+		wantTimedOut  bool
+	}{
+		{"Success", "echo hello", Options{}, "hello\n", 0, false, false},
+		{"Exit code", "echo fail >&2; exit 3", Options{}, "fail\n", 3, false, false},
+		{"Truncated", "printf 0123456789", Options{MaxOutput: 4}, "0123", 0, true, false},
+		{"Timeout", "sleep 5", Options{Timeout: 100 * time.Millisecond}, "", -1, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Run(context.Background(), tt.command, tt.opts)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if res.Output != tt.wantOutput || res.ExitCode != tt.wantExitCode || res.Truncated != tt.wantTruncated || res.TimedOut != tt.wantTimedOut {
+				t.Errorf("Run() = %+v", res)
+			}
+		})
+	}
+}
+
+func TestRunOutsideProject(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if _, err := Run(context.Background(), "true", Options{Dir: ".."}); err == nil {
+		t.Error("Run() error = nil, want error for a directory outside of the project")
+	}
+}
+
+func TestScrubEnv(t *testing.T) {
+	env := []string{
+		"PATH=/usr/bin", "OPENAI_API_KEY=sk-1", "GITHUB_TOKEN=ghp", "HOME=/home/user", "DB_PASSWORD=x",
+		"AWS_SECRET_ACCESS_KEY=s", "AWS_SESSION_TOKEN=t", "NPMTOKEN=n", "NPM_CONFIG__AUTH=a", "DB_PASS=p",
+		"XAUTHORITY=/run/user/1000/xauth", "DBUS_SESSION_BUS_ADDRESS=unix:path=/run/user/1000/bus",
+		"XDG_SESSION_TYPE=wayland", "XDG_SESSION_ID=2", "SSH_AUTH_SOCK=/run/user/1000/ssh-agent", "KEYTIMEOUT=1",
+	}
+	want := []string{
+		"PATH=/usr/bin", "HOME=/home/user",
+		"XAUTHORITY=/run/user/1000/xauth", "DBUS_SESSION_BUS_ADDRESS=unix:path=/run/user/1000/bus",
+		"XDG_SESSION_TYPE=wayland", "XDG_SESSION_ID=2", "SSH_AUTH_SOCK=/run/user/1000/ssh-agent", "KEYTIMEOUT=1",
+	}
+	if got := scrubEnv(env); !reflect.DeepEqual(got, want) {
+		t.Errorf("scrubEnv() = %v, want %v", got, want)
+	}
+}
+
+func TestReport(t *testing.T) {
+	res := Result{ExitCode: 2, Truncated: true, MaxOutput: 10}
+	if got, want := res.Report(), "exit status 2\noutput truncated to 10 bytes"; got != want {
+		t.Errorf("Report() = %q, want %q", got, want)
+	}
+	res = Result{ExitCode: -1, TimedOut: true, Timeout: time.Second}
+	if got, want := res.Report(), "timed out after 1s"; got != want {
+		t.Errorf("Report() = %q, want %q", got, want)
+	}
+}
+
+func TestRunStream(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not available")
+	}
+	t.Chdir(t.TempDir())
+	var stream strings.Builder
+	res, err := Run(context.Background(), "echo one; echo two >&2", Options{MaxOutput: 4, Stream: &stream})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if stream.String() != "one\ntwo\n" || res.Output != "one\n" || !res.Truncated {
+		t.Errorf("Run() streamed %q, captured %q", stream.String(), res.Output)
+	}
+}
+
+func TestRunKillsProcessGroup(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is not available")
+	}
+	t.Chdir(t.TempDir())
+	start := time.Now()
+	res, err := Run(context.Background(), "sleep 5 & sleep 5; wait", Options{Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !res.TimedOut || time.Since(start) > 3*time.Second {
+		t.Errorf("Run() = %+v after %s", res, time.Since(start))
+	}
+}
@@ -0,0 +1,102 @@
+package llmx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/thxrsxm/harzmind-code/internal/agent"
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/logger"
+	"github.com/thxrsxm/harzmind-code/internal/output"
+	"github.com/thxrsxm/rnbw"
+)
+
+// AgentPolicy configures agent mode, in which the model works with tool calls
+// instead of receiving the whole codebase with every message.
+type AgentPolicy struct {
+	// Enabled starts the application in agent mode (/agent switches it at runtime).
+	Enabled bool `json:"enabled"`
+	// MaxIterations is the maximum number of requests per message before the agent is stopped.
+	MaxIterations int `json:"maxIterations"`
+}
+
+// DefaultAgentPolicy returns the agent policy used when none is configured.
+func DefaultAgentPolicy() AgentPolicy {
+	return AgentPolicy{
+		Enabled:       false,
+		MaxIterations: 20,
+	}
+}
+
+// AgentMode reports whether agent mode is enabled.
+func (l *LLMx) AgentMode() bool {
+	return l.agent.Enabled
+}
+
+// SetAgentMode enables or disables agent mode.
+func (l *LLMx) SetAgentMode(enabled bool) {
+	l.agent.Enabled = enabled
+}
+
+// runAgent sends the conversation with the tool definitions to the target and executes
+// the requested tool calls until the model answers without calling a tool.
+// The assistant messages with tool calls and the tool results are appended to the history,
+// the secrets of the results are replaced with placeholders.
+// Every call is displayed and logged. It returns the final reply with the usage summed over
+// all requests (nil if a request reported none) and the context size of the last request.
+func (l *LLMx) runAgent(ctx context.Context, target Target) (api.Reply, int, error) {
+	total := &api.Usage{}
+	for i := 0; i < l.agent.MaxIterations; i++ {
+		ctx, s := startSpinner(ctx, " Querying LLM...")
+		logger.Log(logger.INFO, "agent request %d", i+1)
+		reply, err := api.SendMessageWithTools(ctx, target.Provider, target.Model, l.messages, agent.Tools)
+		s.Stop()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return api.Reply{}, 0, err
+		}
+		if reply.Usage != nil && total != nil {
+			sum := total.Add(*reply.Usage)
+			total = &sum
+		} else {
+			total = nil
+		}
+		if len(reply.ToolCalls) == 0 {
+			tokens := 0
+			if reply.Usage != nil {
+				tokens = reply.Usage.PromptTokens + reply.Usage.CompletionTokens
+			}
+			reply.Usage = total
+			return reply, tokens, nil
+		}
+		l.messages = append(l.messages, api.Message{
+			Role:      "assistant",
+			Content:   reply.Content,
+			ToolCalls: reply.ToolCalls,
+		})
+		for _, call := range reply.ToolCalls {
+			rnbw.ForegroundColor(rnbw.Gray)
+			output.Printf("> %s %s\n", call.Function.Name, call.Function.Arguments)
+			rnbw.ResetColor()
+			logger.Log(logger.INFO, "tool call %s %s", call.Function.Name, call.Function.Arguments)
+			// The tools work with the secrets, the model only sees their placeholders
+			restored := call
+			restored.Function.Arguments = l.redactor.RestoreJSON(call.Function.Arguments)
+			result, err := agent.Execute(ctx, restored, l.tools)
+			if err != nil {
+				return api.Reply{}, 0, err
+			}
+			result, findings := l.redactor.Redact(call.Function.Name+" result", result)
+			reportRedactions(findings)
+			logger.Log(logger.INFO, "tool result of %s (%d bytes)", call.Function.Name, len(result))
+			l.messages = append(l.messages, api.Message{
+				Role:       "tool",
+				Content:    result,
+				ToolCallID: call.ID,
+			})
+		}
+	}
+	return api.Reply{}, 0, fmt.Errorf("no final answer after %d iterations", l.agent.MaxIterations)
+}
@@ -0,0 +1,58 @@
+package llmx
+
+import (
+	"strings"
+)
+
+// attachmentsHeading separates the attachments from the text of a user message.
+const attachmentsHeading string = "\n\n## Attachments"
+
+// attachment is a labeled text sent along with a user message.
+type attachment struct {
+	label   string
+	content string
+}
+
+// Attach adds a labeled text (e.g. the output of a command) to the next user message.
+// Attachments are kept until a message was answered successfully.
+func (l *LLMx) Attach(label, content string) {
+	l.pending = append(l.pending, attachment{label: label, content: content})
+}
+
+// PendingAttachments returns the number of attachments waiting for the next user message.
+func (l *LLMx) PendingAttachments() int {
+	return len(l.pending)
+}
+
+// hasAttachments reports whether the message already contains attachments (e.g. a resent message).
+func hasAttachments(msg string) bool {
+	return strings.Contains(msg, attachmentsHeading)
+}
+
+// withAttachments appends the attachments to the message as labeled code blocks
+// below the attachments heading.
+func withAttachments(msg string, attachments []attachment) string {
+	if len(attachments) == 0 {
+		return msg
+	}
+	var sb strings.Builder
+	sb.WriteString(msg)
+	if !hasAttachments(msg) {
+		sb.WriteString(attachmentsHeading)
+	}
+	for _, a := range attachments {
+		sb.WriteString("\n\n")
+		sb.WriteString(formatAttachment(a.label, a.content))
+	}
+	return sb.String()
+}
+
+// formatAttachment formats the content as a code block with the label as heading.
+// The fence is longer than any backtick sequence of the content.
+func formatAttachment(label, content string) string {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	return "### " + label + "\n\n" + fence + "\n" + strings.TrimRight(content, "\n") + "\n" + fence
+}
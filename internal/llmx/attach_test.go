@@ -0,0 +1,83 @@
+package llmx
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWithAttachments(t *testing.T) {
+	if got := withAttachments("fix it", nil); got != "fix it" {
+		t.Errorf("withAttachments() without attachments = %q", got)
+	}
+	attachments := []attachment{
+		{label: "Output of `go test` (exit status 1)", content: "--- FAIL\n"},
+		{label: "File `README.md`", content: "```go\nx\n```\n"},
+	}
+	want := "fix it\n\n## Attachments\n\n" +
+		"### Output of `go test` (exit status 1)\n\n```\n--- FAIL\n```\n\n" +
+		"### File `README.md`\n\n````\n```go\nx\n```\n````"
+	got := withAttachments("fix it", attachments)
+	if got != want {
+		t.Errorf("withAttachments() = %q, want %q", got, want)
+	}
+	// References of the attachments are not parsed again
+	if refs := parseReferences(got); len(refs) != 0 {
+		t.Errorf("parseReferences() of the attachments = %+v", refs)
+	}
+}
+
+func TestParseReferences(t *testing.T) {
+	msg := "!go vet ./... @ignored\n" +
+		"Why does @main.go:10-40 fail, see @internal/app/ and @README.md. Also !`git status` " +
+		"but not `@code` or mail@example.com\n" +
+		"```\n@fenced.go\n```"
+	want := []reference{
+		{kind: refCommand, command: "go vet ./... @ignored"},
+		{kind: refFile, path: "main.go", start: 10, end: 40},
+		{kind: refDir, path: "internal/app"},
+		{kind: refFile, path: "README.md"},
+		{kind: refCommand, command: "git status"},
+	}
+	if got := parseReferences(msg); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseReferences() = %+v, want %+v", got, want)
+	}
+	// Prose and markdown starting with `!` are no commands
+	for msg, want := range map[string][]reference{
+		"!important: see @x":             {{kind: refFile, path: "x"}},
+		"!!":                             {},
+		"![img](x.png)":                  {},
+		"!no-such-command-hzmind --help": {},
+	} {
+		if got := parseReferences(msg); !reflect.DeepEqual(got, want) {
+			t.Errorf("parseReferences(%q) = %+v, want %+v", msg, got, want)
+		}
+	}
+}
+
+func TestFileAttachment(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("main.go", []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join("node_modules", "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	got, err := fileAttachment(reference{kind: refFile, path: "main.go", start: 2, end: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].label != "File `main.go` (lines 2-3)" || got[0].content != "b\nc\n" {
+		t.Errorf("fileAttachment() = %+v", got)
+	}
+	if _, err := fileAttachment(reference{kind: refFile, path: "node_modules/x/y.js"}); err == nil {
+		t.Error("fileAttachment() of an ignored path succeeded")
+	}
+	if err := os.WriteFile("logo.png", []byte("\x89PNG\r\n\x1a\n\x00\x00"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fileAttachment(reference{kind: refFile, path: "logo.png"}); err == nil || err.Error() != "logo.png is binary (10 bytes)" {
+		t.Errorf("fileAttachment() of a binary file error = %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package llmx
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/thxrsxm/harzmind-code/internal/api"
+)
+
+// DEFAULT_BRANCH is the name of the branch a conversation starts on.
+const DEFAULT_BRANCH string = "main"
+
+// Branch is a fork of the conversation history that is currently not checked out.
+type Branch struct {
+	Messages []api.Message `json:"messages"`
+	Tokens   int           `json:"tokens"`
+}
+
+// LastUserMessage returns the content of the last user message of the conversation
+// with its redacted secrets restored, so that it can be edited and resent.
+// Placeholders of a resumed conversation stay, their secrets are unknown.
+func (l *LLMx) LastUserMessage() (string, bool) {
+	i := l.lastTurnStart()
+	if i < 0 {
+		return "", false
+	}
+	return l.redactor.Restore(l.messages[i].Content), true
+}
+
+// Undo removes the last turn (the last user message and the answer) from the conversation.
+func (l *LLMx) Undo() error {
+	i := l.lastTurnStart()
+	if i < 0 {
+		return fmt.Errorf("nothing to undo")
+	}
+	l.tokens = max(l.tokens-l.countTokens(l.messages[i:]), 0)
+	l.messages = l.messages[:i]
+	return nil
+}
+
+// Resend replaces the last turn by a new user message sent to the target (see HandleUserMessage).
+// If the request fails, the replaced turn is restored.
+func (l *LLMx) Resend(ctx context.Context, msg string, target Target, onChunk func(string)) (string, error) {
+	i := l.lastTurnStart()
+	if i < 0 {
+		return "", fmt.Errorf("no message to resend")
+	}
+	removed := slices.Clone(l.messages[i:])
+	tokens := l.tokens
+	l.messages = l.messages[:i]
+	resp, err := l.HandleUserMessage(ctx, msg, target, onChunk)
+	if err != nil {
+		l.messages = append(l.messages, removed...)
+		l.tokens = tokens
+		return "", err
+	}
+	return resp, nil
+}
+
+// CreateBranch forks the conversation at the current point into a new branch and checks it out.
+func (l *LLMx) CreateBranch(name string) error {
+	if _, exists := l.branches[name]; exists || name == l.branch {
+		return fmt.Errorf("branch '%s' already exists", name)
+	}
+	l.branches[l.branch] = Branch{Messages: slices.Clone(l.history()), Tokens: l.tokens}
+	l.branch = name
+	return nil
+}
+
+// Checkout switches to another branch of the conversation.
+// The history of the current branch is kept and can be checked out again.
+func (l *LLMx) Checkout(name string) error {
+	if name == l.branch {
+		return fmt.Errorf("already on branch '%s'", name)
+	}
+	b, exists := l.branches[name]
+	if !exists {
+		return fmt.Errorf("branch '%s' not found", name)
+	}
+	l.branches[l.branch] = Branch{Messages: slices.Clone(l.history()), Tokens: l.tokens}
+	delete(l.branches, name)
+	l.branch = name
+	l.messages = append([]api.Message{{Role: "system", Content: ""}}, b.Messages...)
+	l.tokens = b.Tokens
+	return nil
+}
+
+// GetBranch returns the name of the checked out branch.
+func (l *LLMx) GetBranch() string {
+	return l.branch
+}
+
+// GetBranches returns the branches that are not checked out.
+func (l *LLMx) GetBranches() map[string]Branch {
+	return l.branches
+}
+
+// BranchNames returns the sorted names of all branches including the checked out one.
+func (l *LLMx) BranchNames() []string {
+	names := append(slices.Collect(maps.Keys(l.branches)), l.branch)
+	slices.Sort(names)
+	return names
+}
+
+// RestoreBranches replaces the branches with saved ones.
+func (l *LLMx) RestoreBranches(current string, branches map[string]Branch) {
+	if len(current) == 0 {
+		current = DEFAULT_BRANCH
+	}
+	if branches == nil {
+		branches = map[string]Branch{}
+	}
+	l.branch = current
+	l.branches = branches
+	for _, b := range branches {
+		l.reservePlaceholders(b.Messages)
+	}
+}
+
+// lastTurnStart returns the index of the last user message, or -1 if there is none.
+func (l *LLMx) lastTurnStart() int {
+	for i := len(l.messages) - 1; i > 0; i-- {
+		if l.messages[i].Role == "user" {
+			return i
+		}
+	}
+	return -1
+}
+
+// countTokens counts the tokens of the messages with the tokenizer of the last used model.
+func (l *LLMx) countTokens(messages []api.Message) int {
+	model := ""
+	if len(l.turns) > 0 {
+		model = l.turns[len(l.turns)-1].Model
+	}
+	return countTokens(encodingForModel(model), messages)
+}
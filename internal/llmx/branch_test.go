@@ -0,0 +1,59 @@
+package llmx
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/thxrsxm/harzmind-code/internal/agent"
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/policy"
+)
+
+func TestUndo(t *testing.T) {
+	l := NewLLMx(DefaultCompactionPolicy(), DefaultAgentPolicy(), agent.Env{Commands: policy.NewChecker()})
+	if err := l.Undo(); err == nil {
+		t.Errorf("Undo() of an empty conversation succeeded")
+	}
+	l.Restore([]api.Message{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "answer"},
+		{Role: "user", Content: "second"},
+		{Role: "assistant", Content: "answer"},
+	}, nil, 100)
+	if err := l.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := l.LastUserMessage(); got != "first" || len(l.GetMessages()) != 2 {
+		t.Errorf("after Undo() last message = %q, %d messages", got, len(l.GetMessages()))
+	}
+	if l.GetTokens() >= 100 {
+		t.Errorf("after Undo() tokens = %d, want less than 100", l.GetTokens())
+	}
+}
+
+func TestBranches(t *testing.T) {
+	l := NewLLMx(DefaultCompactionPolicy(), DefaultAgentPolicy(), agent.Env{Commands: policy.NewChecker()})
+	l.Restore([]api.Message{{Role: "user", Content: "first"}, {Role: "assistant", Content: "answer"}}, nil, 10)
+	if err := l.CreateBranch("idea"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.CreateBranch(DEFAULT_BRANCH); err == nil {
+		t.Errorf("CreateBranch() of an existing branch succeeded")
+	}
+	l.Restore(append(l.GetMessages(), api.Message{Role: "user", Content: "second"}), nil, 20)
+	if err := l.Checkout(DEFAULT_BRANCH); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.GetMessages()) != 2 || l.GetTokens() != 10 {
+		t.Errorf("on %s: %d messages, %d tokens", DEFAULT_BRANCH, len(l.GetMessages()), l.GetTokens())
+	}
+	if err := l.Checkout("idea"); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.GetMessages()) != 3 || l.GetTokens() != 20 {
+		t.Errorf("on idea: %d messages, %d tokens", len(l.GetMessages()), l.GetTokens())
+	}
+	if got := l.BranchNames(); !slices.Equal(got, []string{"idea", DEFAULT_BRANCH}) {
+		t.Errorf("BranchNames() = %v", got)
+	}
+}
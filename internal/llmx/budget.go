@@ -0,0 +1,147 @@
+package llmx
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pkoukk/tiktoken-go"
+
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/codebase"
+	"github.com/thxrsxm/harzmind-code/internal/input"
+	"github.com/thxrsxm/harzmind-code/internal/logger"
+	"github.com/thxrsxm/harzmind-code/internal/output"
+)
+
+const (
+	// maxResponseReserve is the maximum number of tokens kept free for the response.
+	maxResponseReserve int = 8192
+	// topFiles is the number of files shown in the breakdown of an oversized request.
+	topFiles int = 10
+)
+
+// fileTokens is the token count of a single codebase file.
+type fileTokens struct {
+	path   string
+	tokens int
+}
+
+// responseReserve returns the number of tokens of the context window kept free for the response.
+func responseReserve(window int) int {
+	return min(window/4, maxResponseReserve)
+}
+
+// fitContext builds the system prompt and makes sure that the request fits into the context window.
+// The request consists of the system prompt, the history and the new user message.
+// If it exceeds the window (minus a reserve for the response), the files consuming the most
+// tokens are shown and the user chooses to drop the oldest turns, exclude the largest files,
+// or abort. The check is skipped if the context window of the target is unknown.
+func (l *LLMx) fitContext(target Target, readme string, files []codebase.File, msg string) (string, error) {
+	encoding := encodingForModel(target.Model)
+	budget := target.ContextWindow - responseReserve(target.ContextWindow)
+	for {
+		// Build the system prompt without the excluded files
+		included := slices.DeleteFunc(slices.Clone(files), func(f codebase.File) bool { return l.excluded[f.Path] })
+		sysPrompt, err := l.buildSystemPrompt(readme, included)
+		if err != nil {
+			return "", err
+		}
+		if target.ContextWindow <= 0 {
+			return sysPrompt, nil
+		}
+		// Count the tokens of the complete request
+		messages := append(slices.Clone(l.history()), api.Message{Role: "user", Content: msg})
+		count := l.promptTokens(encoding, sysPrompt, readme, included) + countTokens(encoding, messages)
+		if count <= budget {
+			return sysPrompt, nil
+		}
+		logger.Log(logger.WARNING, "request needs %d tokens, budget is %d tokens", count, budget)
+		// Show which files consume the most tokens
+		ranked := l.rankFiles(encoding, included)
+		output.PrintfWarning("the request needs %d tokens, but the context window of '%s' allows %d tokens (%d reserved for the response)\n",
+			count, target.Model, budget, target.ContextWindow-budget)
+		output.Println("Largest files:")
+		for _, f := range ranked[:min(topFiles, len(ranked))] {
+			output.Printf("%8d  %s\n", f.tokens, f.path)
+		}
+		// Let the user choose how to reduce the request
+		output.Print("[d]rop oldest turns, [e]xclude largest files, [a]bort: ")
+		choice, err := input.ReadInput(false)
+		if err != nil {
+			return "", err
+		}
+		over := count - budget
+		switch strings.ToLower(choice) {
+		case "d", "drop":
+			if n := l.dropOldestTurns(encoding, over); n == 0 {
+				output.PrintWarning("no turns to drop\n")
+			} else {
+				output.Printf("Dropped %d turns\n", n)
+			}
+		case "e", "exclude":
+			if n := l.excludeLargestFiles(ranked, over); n == 0 {
+				output.PrintWarning("no files to exclude\n")
+			} else {
+				output.Printf("Excluded %d files until the context is cleared\n", n)
+			}
+		default:
+			return "", fmt.Errorf("request exceeds the context window of '%s'", target.Model)
+		}
+	}
+}
+
+// history returns the messages of the conversation without the system prompt.
+func (l *LLMx) history() []api.Message {
+	if len(l.messages) == 0 {
+		return nil
+	}
+	return l.messages[1:]
+}
+
+// dropOldestTurns removes the oldest turns from the history until at least
+// the given number of tokens is freed. A turn starts with a user message.
+// Returns the number of dropped turns.
+func (l *LLMx) dropOldestTurns(encoding *tiktoken.Tiktoken, tokens int) int {
+	dropped := 0
+	freed := 0
+	for freed < tokens && len(l.messages) > 1 {
+		// Find the start of the next turn
+		end := 2
+		for end < len(l.messages) && l.messages[end].Role != "user" {
+			end++
+		}
+		freed += countTokens(encoding, l.messages[1:end])
+		l.messages = slices.Delete(l.messages, 1, end)
+		dropped++
+	}
+	return dropped
+}
+
+// excludeLargestFiles excludes the largest files until at least the given number of tokens is freed.
+// Returns the number of excluded files.
+func (l *LLMx) excludeLargestFiles(ranked []fileTokens, tokens int) int {
+	excluded := 0
+	freed := 0
+	for _, f := range ranked {
+		if freed >= tokens {
+			break
+		}
+		l.excluded[f.path] = true
+		logger.Log(logger.INFO, "excluded '%s' (%d tokens) from the context", f.path, f.tokens)
+		freed += f.tokens
+		excluded++
+	}
+	return excluded
+}
+
+// rankFiles counts the tokens of every file and sorts the files by descending token count.
+func (l *LLMx) rankFiles(encoding *tiktoken.Tiktoken, files []codebase.File) []fileTokens {
+	ranked := make([]fileTokens, 0, len(files))
+	for _, f := range files {
+		ranked = append(ranked, fileTokens{path: f.Path, tokens: l.countFile(encoding, f)})
+	}
+	slices.SortStableFunc(ranked, func(a, b fileTokens) int { return cmp.Compare(b.tokens, a.tokens) })
+	return ranked
+}
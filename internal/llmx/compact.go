@@ -0,0 +1,132 @@
+package llmx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/logger"
+	"github.com/thxrsxm/harzmind-code/internal/output"
+	"github.com/thxrsxm/rnbw"
+)
+
+// summaryPrefix introduces the synthetic message that replaces compacted turns.
+const summaryPrefix string = "Summary of the earlier conversation:\n\n"
+
+// summaryInstruction is the system prompt of the summarization request.
+const summaryInstruction string = `You summarize a conversation between a user and an AI programming assistant about a codebase.
+Write a concise summary that preserves everything needed to continue the conversation:
+the user's goals and requirements, decisions made, files and identifiers discussed,
+code changes that were proposed or agreed on, and open questions.
+Reply with the summary only.`
+
+// CompactionPolicy configures the automatic summarization of long conversations.
+type CompactionPolicy struct {
+	// Enabled turns on automatic compaction (/compact works regardless).
+	Enabled bool `json:"enabled"`
+	// Threshold is the context size in tokens above which the conversation is compacted.
+	// 0 uses 75% of the context window of the model.
+	Threshold int `json:"threshold"`
+	// KeepTurns is the number of most recent turns kept verbatim.
+	KeepTurns int `json:"keepTurns"`
+}
+
+// DefaultCompactionPolicy returns the compaction policy used when none is configured.
+func DefaultCompactionPolicy() CompactionPolicy {
+	return CompactionPolicy{
+		Enabled:   false,
+		Threshold: 0,
+		KeepTurns: 4,
+	}
+}
+
+// shouldCompact reports whether the conversation exceeds the compaction threshold.
+func (l *LLMx) shouldCompact(target Target) bool {
+	if !l.compaction.Enabled {
+		return false
+	}
+	threshold := l.compaction.Threshold
+	if threshold <= 0 {
+		threshold = target.ContextWindow * 3 / 4
+	}
+	return threshold > 0 && l.tokens > threshold
+}
+
+// Compact asks the model of the target to summarize all but the most recent turns
+// and replaces them with a single synthetic system message containing the summary.
+// The number of kept turns is taken from the compaction policy.
+// The summary is printed, so that it is part of the transcript.
+func (l *LLMx) Compact(ctx context.Context, target Target) error {
+	// Split the history into older turns and the kept turns
+	turns := l.turnStarts()
+	keep := max(l.compaction.KeepTurns, 0)
+	if len(turns) <= keep {
+		return fmt.Errorf("nothing to compact")
+	}
+	cut := len(l.messages)
+	if keep > 0 {
+		cut = turns[len(turns)-keep]
+	}
+	older := l.messages[1:cut]
+	logger.Log(logger.INFO, "compacting %d messages", len(older))
+	// Ask the model for a summary of the older turns
+	request := []api.Message{
+		{Role: "system", Content: summaryInstruction},
+		{Role: "user", Content: formatTranscript(older)},
+	}
+	ctx, s := startSpinner(ctx, " Summarizing conversation...")
+	reply, err := api.SendMessage(ctx, target.Provider, target.Model, request)
+	s.Stop()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		logger.Log(logger.ERROR, "compaction failed: %v", err)
+		return err
+	}
+	turn := newTurn(target.Model, reply.Usage, request, reply.Content)
+	turn.Summary = true
+	l.turns = append(l.turns, turn)
+	// Replace the older turns by the summary
+	summary := api.Message{Role: "system", Content: summaryPrefix + strings.TrimSpace(reply.Content)}
+	l.messages = append([]api.Message{l.messages[0], summary}, l.messages[cut:]...)
+	l.tokens = countTokens(encodingForModel(target.Model), l.messages)
+	// Show the summary
+	rnbw.ForegroundColor(rnbw.Green)
+	output.Printf("Compacted %d messages into a summary\n", len(older))
+	rnbw.ResetColor()
+	output.Println()
+	output.Println(summary.Content)
+	logger.Log(logger.INFO, "compacted %d messages", len(older))
+	return nil
+}
+
+// turnStarts returns the indices of the messages that start a turn (user messages).
+func (l *LLMx) turnStarts() []int {
+	starts := []int{}
+	for i, msg := range l.messages {
+		if msg.Role == "user" {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// formatTranscript formats messages as a Markdown transcript for the summarization request.
+func formatTranscript(messages []api.Message) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		switch msg.Role {
+		case "user":
+			sb.WriteString("## User\n\n")
+		case "assistant":
+			sb.WriteString("## Assistant\n\n")
+		default:
+			sb.WriteString("## Context\n\n")
+		}
+		sb.WriteString(strings.TrimPrefix(msg.Content, summaryPrefix))
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,96 @@
+package llmx
+
+import (
+	"encoding/json"
+
+	"github.com/pkoukk/tiktoken-go"
+
+	"github.com/thxrsxm/harzmind-code/internal/codebase"
+	"github.com/thxrsxm/harzmind-code/internal/edits"
+	"github.com/thxrsxm/harzmind-code/internal/logger"
+	"github.com/thxrsxm/harzmind-code/internal/output"
+	"github.com/thxrsxm/rnbw"
+)
+
+// cachedTokens is the token count of a serialized codebase file.
+type cachedTokens struct {
+	content  string
+	encoding *tiktoken.Tiktoken
+	tokens   int
+}
+
+// codebasePrefix returns the part of the system prompt before the serialized codebase.
+func codebasePrefix(readme string) string {
+	return readme + "\n\n" + edits.Instructions + "\n\n## Codebase\n\n"
+}
+
+// promptTokens counts the tokens of the system prompt. The codebase is counted per file
+// with the cached counts of the unchanged files, which is exact up to the boundaries of the files.
+func (l *LLMx) promptTokens(encoding *tiktoken.Tiktoken, sysPrompt, readme string, files []codebase.File) int {
+	if l.agent.Enabled {
+		return countText(encoding, sysPrompt)
+	}
+	// The brackets and commas of the JSON array
+	count := countText(encoding, codebasePrefix(readme)) + len(files) + 1
+	for _, f := range files {
+		count += l.countFile(encoding, f)
+	}
+	return count
+}
+
+// countFile returns the number of tokens of the serialized file.
+// The count is cached and only recomputed when the content or the tokenizer changes.
+func (l *LLMx) countFile(encoding *tiktoken.Tiktoken, f codebase.File) int {
+	if c, ok := l.tokenCache[f.Path]; ok && c.encoding == encoding && c.content == f.Content {
+		return c.tokens
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return countText(encoding, f.Content)
+	}
+	tokens := countText(encoding, string(data))
+	l.tokenCache[f.Path] = cachedTokens{content: f.Content, encoding: encoding, tokens: tokens}
+	return tokens
+}
+
+// trackChanges compares the files with the files of the last turn and prints the number of
+// added, modified and removed files. The token counts of removed files are dropped from the cache.
+func (l *LLMx) trackChanges(files []codebase.File) {
+	current := make(map[string]string, len(files))
+	for _, f := range files {
+		current[f.Path] = f.Content
+	}
+	previous := l.lastFiles
+	l.lastFiles = current
+	for path := range l.tokenCache {
+		if _, ok := current[path]; !ok {
+			delete(l.tokenCache, path)
+		}
+	}
+	// Nothing to compare with in the first turn
+	if previous == nil {
+		return
+	}
+	changed := 0
+	for path, content := range current {
+		if old, ok := previous[path]; !ok || old != content {
+			changed++
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			changed++
+		}
+	}
+	if changed == 0 {
+		return
+	}
+	noun := "files"
+	if changed == 1 {
+		noun = "file"
+	}
+	rnbw.ForegroundColor(rnbw.Gray)
+	output.Printf("%d %s changed since last turn\n", changed, noun)
+	rnbw.ResetColor()
+	logger.Log(logger.INFO, "%d %s changed since last turn", changed, noun)
+}
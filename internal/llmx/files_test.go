@@ -0,0 +1,32 @@
+package llmx
+
+import (
+	"testing"
+
+	"github.com/thxrsxm/harzmind-code/internal/agent"
+	"github.com/thxrsxm/harzmind-code/internal/codebase"
+)
+
+func TestCountFile(t *testing.T) {
+	l := NewLLMx(DefaultCompactionPolicy(), DefaultAgentPolicy(), agent.Env{})
+	encoding := encodingForModel("gpt-4o")
+	f := codebase.File{Name: "main.go", Path: "main.go", Content: "package main\n"}
+	tokens := l.countFile(encoding, f)
+	if tokens <= 0 {
+		t.Fatalf("countFile() = %d", tokens)
+	}
+	// Unchanged files are not counted again
+	l.tokenCache[f.Path] = cachedTokens{content: f.Content, encoding: encoding, tokens: 999}
+	if got := l.countFile(encoding, f); got != 999 {
+		t.Errorf("countFile() of an unchanged file = %d, want the cached 999", got)
+	}
+	f.Content = "package app\n"
+	if got := l.countFile(encoding, f); got == 999 {
+		t.Error("countFile() of a modified file returned the cached count")
+	}
+	// Removed files are dropped from the cache
+	l.trackChanges([]codebase.File{{Name: "a.go", Path: "a.go", Content: "package a\n"}})
+	if _, ok := l.tokenCache[f.Path]; ok {
+		t.Error("token count of a removed file was kept")
+	}
+}
@@ -1,46 +1,135 @@
 // Package llmx provides a high-level abstraction for interacting with LLMs
 // in the HarzMind Code application. It manages conversation history (messages),
-// token counting (using tiktoken), and integrates visual feedback (spinner)
-// during API calls. It also dynamically constructs the system prompt by
+// token usage accounting (reported by the API or counted with tiktoken), and
+// integrates visual feedback (spinner) during API calls. It also dynamically constructs the system prompt by
 // embedding the project’s README (`HZMIND.md`) and a serialized codebase snapshot.
 package llmx
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/briandowns/spinner"
-	"github.com/pkoukk/tiktoken-go"
 
+	"github.com/thxrsxm/harzmind-code/internal/agent"
 	"github.com/thxrsxm/harzmind-code/internal/api"
 	"github.com/thxrsxm/harzmind-code/internal/codebase"
 	"github.com/thxrsxm/harzmind-code/internal/common"
 	"github.com/thxrsxm/harzmind-code/internal/logger"
 	"github.com/thxrsxm/harzmind-code/internal/output"
+	"github.com/thxrsxm/harzmind-code/internal/secrets"
+	"github.com/thxrsxm/harzmind-code/internal/snapshot"
 )
 
 // LLMx encapsulates the state of a single LLM conversation session.
-// It maintains the full message history and tracks total token usage.
+// It maintains the full message history, the current context size and the usage of every turn.
+// Files excluded to fit the context window stay excluded until the history is cleared.
+// The history can be forked into branches, of which one is checked out at a time.
 type LLMx struct {
-	tokens   int
-	messages []api.Message
+	tokens     int
+	messages   []api.Message
+	turns      []Turn
+	excluded   map[string]bool
+	compaction CompactionPolicy
+	agent      AgentPolicy
+	tools      agent.Env
+	pending    []attachment
+	redactor   *secrets.Redactor
+	tokenCache map[string]cachedTokens
+	lastFiles  map[string]string
+	branch     string
+	branches   map[string]Branch
+}
+
+// Target describes the model a message is sent to.
+type Target struct {
+	Provider api.Provider
+	Model    string
+	// ContextWindow is the context window of the model in tokens (0 if unknown).
+	ContextWindow int
 }
 
 // NewLLMx creates and returns a new LLMx instance initialized with an empty conversation.
+// The compaction policy controls the summarization of long conversations,
+// the agent policy the use of tool calls (see AgentPolicy) and the tool environment
+// the commands run by the model in agent mode.
 // The returned LLMx is ready to receive user messages via HandleUserMessage.
-func NewLLMx() *LLMx {
-	return &LLMx{tokens: 0, messages: []api.Message{}}
+func NewLLMx(compaction CompactionPolicy, agentPolicy AgentPolicy, tools agent.Env) *LLMx {
+	return &LLMx{
+		tokens:     0,
+		messages:   []api.Message{},
+		excluded:   map[string]bool{},
+		compaction: compaction,
+		agent:      agentPolicy,
+		tools:      tools,
+		redactor:   secrets.NewRedactor(),
+		tokenCache: map[string]cachedTokens{},
+		branch:     DEFAULT_BRANCH,
+		branches:   map[string]Branch{},
+	}
 }
 
-// HandleUserMessage sends a user message to the LLM API and returns the AI’s response.
+// HandleUserMessage sends a user message to the model of the target and returns the AI’s response.
+// The references of the message (`@file`, `@file:10-40`, `@dir/`, `!command`) are expanded and
+// appended to the message together with the pending attachments (see Attach). The references of a
+// resent message that already contains attachments are not expanded again.
+// A snapshot of the working tree at the start of the turn is stored for every successful turn
+// (see snapshot.Store).
+// Secrets in the files and in the message are replaced with placeholders before sending
+// (see secrets.Redactor); the streamed and the returned response contain the secrets again.
+// Before sending, a conversation that exceeds the compaction threshold is summarized (see Compact)
+// and the request is checked against the context window of the target (see fitContext).
 // It appends the user message to the conversation history, handles the API request
-// with a visual spinner, and updates token usage. If the call fails, the user
+// with a visual spinner, and records the token usage of the turn. If the call fails, the user
 // message is reverted from the history (to preserve conversational integrity).
-func (l *LLMx) HandleUserMessage(msg, apiURL, model, apiKey string) (string, error) {
+// Cancelling ctx aborts the request and is handled like a failed call.
+//
+// If onChunk is not nil, the response is streamed: the spinner stops as soon as the
+// first chunk arrives and onChunk is called for every content fragment. The returned
+// string is always the fully assembled response.
+//
+// In agent mode, the system prompt contains the file tree instead of the file contents
+// and the model calls tools until it answers (see runAgent). The final answer is passed
+// to onChunk as a whole.
+func (l *LLMx) HandleUserMessage(ctx context.Context, msg string, target Target, onChunk func(string)) (string, error) {
+	var refs []attachment
+	if !hasAttachments(msg) {
+		var err error
+		if refs, err = l.expandReferences(ctx, msg); err != nil {
+			return "", err
+		}
+	}
+	msg = withAttachments(msg, append(slices.Clone(l.pending), refs...))
 	logger.Log(logger.INFO, "handling user message (length: %d chars)", len(msg))
-	// Create system prompt
-	sysPrompt, err := createSystemPrompt()
+	// Create system prompt that fits into the context window
+	readme, files, err := loadContext()
+	if err != nil {
+		return "", err
+	}
+	l.trackChanges(files)
+	// Capture the working tree at the start of the turn, it is stored once the turn has succeeded
+	// and discarded otherwise
+	tree := captureSnapshot()
+	defer discardSnapshot(tree)
+	// Replace the secrets of the files and the message with placeholders
+	l.loadAllowlist()
+	files, findings := l.redactFiles(files)
+	msg, found := l.redactor.Redact(SOURCE_MESSAGE, msg)
+	reportRedactions(append(findings, found...))
+	// Summarize older turns of a long conversation
+	if l.shouldCompact(target) {
+		if err := l.Compact(ctx, target); err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			output.PrintfWarning("compaction failed: %v\n", err)
+		}
+	}
+	sysPrompt, err := l.fitContext(target, readme, files, msg)
 	if err != nil {
 		return "", err
 	}
@@ -55,71 +144,165 @@ func (l *LLMx) HandleUserMessage(msg, apiURL, model, apiKey string) (string, err
 		Content: msg,
 	}
 	l.messages = append(l.messages, userMsg)
-	// Initialize and start the spinner for visual feedback
-	// Use a dot spinner style
-	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
-	// Start spinning in a goroutine
-	s.Start()
-	s.Suffix = " Sending codebase and querying LLM..."
-	resp, err := api.SendMessage(apiURL, model, apiKey, l.messages)
-	logger.Log(logger.INFO, "%s", "sending codebase and querying LLM")
-	// Stop the spinner after the call completes
-	s.Stop()
+	start := len(l.messages) - 1
+	var reply api.Reply
+	contextTokens := 0
+	if l.agent.Enabled {
+		reply, contextTokens, err = l.runAgent(ctx, target)
+		if err == nil && onChunk != nil {
+			onChunk(l.redactor.Restore(reply.Content))
+		}
+	} else if onChunk != nil {
+		// Show the secrets in the streamed response, like in the returned one
+		restorer := l.redactor.NewStreamRestorer(onChunk)
+		reply, err = l.sendMessage(ctx, target, restorer.Write)
+		restorer.Flush()
+	} else {
+		reply, err = l.sendMessage(ctx, target, nil)
+	}
 	if err != nil {
 		logger.Log(logger.ERROR, "API call failed for user message: %v", err)
-		// Remove last message from messages (user message)
-		if len(l.messages) >= 1 {
-			l.messages = l.messages[:len(l.messages)-1]
-		}
+		// Remove the user message and the tool calls of the turn from messages
+		l.messages = l.messages[:start]
 		return "", err
 	}
 	logger.Log(logger.INFO, "received response from API for user message")
+	if tree != nil {
+		saveSnapshot(tree)
+	}
 	// Add AI message to messages
 	l.messages = append(l.messages, api.Message{
 		Role:    "assistant",
-		Content: resp,
+		Content: reply.Content,
 	})
-	// Update tokens amount
-	l.updateTokens(model)
-	return resp, nil
+	// Record token usage
+	turn := newTurn(target.Model, reply.Usage, l.messages[:len(l.messages)-1], reply.Content)
+	l.turns = append(l.turns, turn)
+	l.tokens = turn.Usage.PromptTokens + turn.Usage.CompletionTokens
+	if contextTokens > 0 {
+		l.tokens = contextTokens
+	}
+	l.pending = nil
+	return l.redactor.Restore(reply.Content), nil
 }
 
-// GetTokens returns the current total token count across all messages in the session.
-func (l *LLMx) GetTokens() int {
-	return l.tokens
+// captureSnapshot captures all files of the working tree for the snapshot of the turn (see snapshot.Store).
+// A failed capture is reported and returns nil, it does not fail the turn.
+func captureSnapshot() *snapshot.Manifest {
+	paths, err := codebase.ListFiles(".")
+	if err == nil {
+		var m *snapshot.Manifest
+		if m, err = snapshot.NewStore(common.PATH_DIR_SNAPSHOTS).Capture(paths); err == nil {
+			return m
+		}
+	}
+	output.PrintfWarning("saving snapshot: %v\n", err)
+	logger.Log(logger.ERROR, "saving snapshot: %v", err)
+	return nil
 }
 
-// ClearMessages resets the conversation history to empty and resets token count.
-func (l *LLMx) ClearMessages() {
-	l.messages = []api.Message{}
-	l.updateTokens("")
+// saveSnapshot stores the captured snapshot with the next turn number.
+// A failed save is reported but does not fail the turn.
+func saveSnapshot(m *snapshot.Manifest) {
+	if err := snapshot.NewStore(common.PATH_DIR_SNAPSHOTS).Save(m); err != nil {
+		output.PrintfWarning("saving snapshot: %v\n", err)
+		logger.Log(logger.ERROR, "saving snapshot: %v", err)
+		return
+	}
+	logger.Log(logger.INFO, "saved snapshot of turn %d (%d files)", m.Turn, len(m.Files))
 }
 
-// updateTokens recalculates and updates the cumulative token count for the conversation.
-// It uses tiktoken to encode all messages with the specified model-specific tokenizer.
-func (l *LLMx) updateTokens(model string) {
-	encoding, err := tiktoken.EncodingForModel(model)
-	if err != nil {
-		// Fallback to cl100k_base (GPT-4 encoding)
-		encoding, _ = tiktoken.GetEncoding("cl100k_base")
+// discardSnapshot removes the contents of a captured snapshot that was not saved.
+// A failed removal is only logged.
+func discardSnapshot(m *snapshot.Manifest) {
+	if err := snapshot.NewStore(common.PATH_DIR_SNAPSHOTS).Discard(m); err != nil {
+		logger.Log(logger.ERROR, "discarding snapshot: %v", err)
 	}
-	count := 0
-	for _, v := range l.messages {
-		count += len(encoding.Encode(v.Content, nil, nil))
+}
+
+// sendMessage sends the conversation to the target with a visual spinner, streamed if onChunk is not nil.
+func (l *LLMx) sendMessage(ctx context.Context, target Target, onChunk func(string)) (api.Reply, error) {
+	// Start the spinner for visual feedback
+	ctx, s := startSpinner(ctx, " Sending codebase and querying LLM...")
+	logger.Log(logger.INFO, "%s", "sending codebase and querying LLM")
+	var reply api.Reply
+	var err error
+	if onChunk != nil {
+		reply, err = api.StreamMessage(ctx, target.Provider, target.Model, l.messages, func(chunk string) {
+			// Stop the spinner before the first chunk is printed
+			s.Stop()
+			onChunk(chunk)
+		})
+	} else {
+		reply, err = api.SendMessage(ctx, target.Provider, target.Model, l.messages)
 	}
-	l.tokens = count
+	// Stop the spinner after the call completes
+	s.Stop()
+	// Report a cancellation as such, regardless of where the request was interrupted
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return reply, err
+}
+
+// startSpinner starts a spinner with the suffix for visual feedback during an API call.
+// The returned context reports retries of failed requests in the spinner.
+func startSpinner(ctx context.Context, suffix string) (context.Context, *spinner.Spinner) {
+	// Use a dot spinner style
+	s := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	s.Suffix = suffix
+	// Start spinning in a goroutine
+	s.Start()
+	ctx = api.WithRetryHook(ctx, func(attempt, maxAttempts int, wait time.Duration, err error) {
+		s.Lock()
+		s.Suffix = fmt.Sprintf(" %v, retrying in %s (attempt %d/%d)...", err, wait.Round(time.Second), attempt, maxAttempts)
+		s.Unlock()
+	})
+	return ctx, s
+}
+
+// GetTokens returns the size of the conversation in tokens as of the last turn.
+func (l *LLMx) GetTokens() int {
+	return l.tokens
+}
+
+// GetMessages returns the conversation history without the system prompt.
+func (l *LLMx) GetMessages() []api.Message {
+	return l.history()
+}
+
+// Restore replaces the conversation with a saved one.
+// The messages must not contain the system prompt, it is rebuilt with the next message.
+func (l *LLMx) Restore(messages []api.Message, turns []Turn, tokens int) {
+	l.messages = append([]api.Message{{Role: "system", Content: ""}}, messages...)
+	l.turns = turns
+	l.tokens = tokens
+	clear(l.excluded)
+	l.reservePlaceholders(messages)
+}
+
+// ClearMessages resets the conversation history of the current branch to empty and resets token count.
+// Files excluded to fit the context window are included again.
+// The usage of past turns is kept for the cost accounting of the session.
+func (l *LLMx) ClearMessages() {
+	l.messages = []api.Message{}
+	l.tokens = 0
+	clear(l.excluded)
 }
 
-// createSystemPrompt builds the system prompt by combining HZMIND.md and the codebase data.
-func createSystemPrompt() (string, error) {
-	// Collect and serialize codebase files
-	files, err := codebase.GetCodeBase(".")
+// loadContext loads HZMIND.md and the codebase files for the system prompt.
+func loadContext() (string, []codebase.File, error) {
+	// Collect codebase files
+	files, skipped, err := codebase.Scan(".")
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
-	jsonCodeBase, err := json.Marshal(files)
-	if err != nil {
-		return "", err
+	// Unreadable files are left out with a warning
+	for _, s := range skipped {
+		if s.Err != nil {
+			output.PrintfWarning("skipping %s: %v\n", s.Path, s.Err)
+			logger.Log(logger.WARNING, "skipping %s: %v", s.Path, s.Err)
+		}
 	}
 	// Load HZMIND.md
 	data, err := os.ReadFile(common.PATH_FILE_README)
@@ -129,6 +312,20 @@ func createSystemPrompt() (string, error) {
 		logger.Log(logger.ERROR, "%v", err)
 		data = []byte{}
 	}
+	return string(data), files, nil
+}
+
+// buildSystemPrompt builds the system prompt by combining HZMIND.md, the instructions
+// for proposing file edits and the serialized codebase files.
+// In agent mode, it contains the tool instructions and the file tree instead.
+func (l *LLMx) buildSystemPrompt(readme string, files []codebase.File) (string, error) {
+	if l.agent.Enabled {
+		return readme + "\n\n" + agent.Instructions + "\n\n## Files\n\n" + codebase.Tree(files), nil
+	}
+	jsonCodeBase, err := json.Marshal(files)
+	if err != nil {
+		return "", err
+	}
 	// Create System Prompt message
-	return string(data) + "\n\n## Codebase\n\n" + string(jsonCodeBase), nil
+	return codebasePrefix(readme) + string(jsonCodeBase), nil
 }
@@ -0,0 +1,82 @@
+package llmx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thxrsxm/harzmind-code/internal/agent"
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/common"
+	"github.com/thxrsxm/harzmind-code/internal/policy"
+	"github.com/thxrsxm/harzmind-code/internal/snapshot"
+)
+
+// fakeProvider answers every message with its reply or fails with its error.
+type fakeProvider struct {
+	reply string
+	err   error
+}
+
+func (p fakeProvider) SendMessage(ctx context.Context, model string, messages []api.Message) (api.Reply, error) {
+	return api.Reply{Content: p.reply}, p.err
+}
+
+func (p fakeProvider) StreamMessage(ctx context.Context, model string, messages []api.Message, onChunk func(string)) (api.Reply, error) {
+	if p.err == nil {
+		onChunk(p.reply)
+	}
+	return api.Reply{Content: p.reply}, p.err
+}
+
+func (p fakeProvider) GetModels(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func TestSnapshotOfTurn(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("main.go", []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := snapshot.NewStore(common.PATH_DIR_SNAPSHOTS)
+	l := NewLLMx(DefaultCompactionPolicy(), DefaultAgentPolicy(), agent.Env{Commands: policy.NewChecker()})
+	failing := Target{Provider: fakeProvider{err: errors.New("unavailable")}, Model: "test"}
+	if _, err := l.HandleUserMessage(context.Background(), "hello", failing, nil); err == nil {
+		t.Fatal("HandleUserMessage() with a failing provider succeeded")
+	}
+	if turns, err := store.Turns(); err != nil || len(turns) != 0 {
+		t.Errorf("after a failed turn: snapshots %v, %v", turns, err)
+	}
+	if len(l.GetMessages()) != 0 {
+		t.Errorf("after a failed turn: %d messages", len(l.GetMessages()))
+	}
+	working := Target{Provider: fakeProvider{reply: "hi"}, Model: "test"}
+	if _, err := l.HandleUserMessage(context.Background(), "hello", working, nil); err != nil {
+		t.Fatal(err)
+	}
+	m, err := store.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Turn != 1 || len(m.Files) != 1 {
+		t.Errorf("after a successful turn: snapshot of turn %d with %d files", m.Turn, len(m.Files))
+	}
+}
+
+func TestLastUserMessageRestoresSecrets(t *testing.T) {
+	t.Chdir(t.TempDir())
+	l := NewLLMx(DefaultCompactionPolicy(), DefaultAgentPolicy(), agent.Env{Commands: policy.NewChecker()})
+	msg := "use the key AKIA" + "IOSFODNN7EXAMPLE"
+	working := Target{Provider: fakeProvider{reply: "ok"}, Model: "test"}
+	if _, err := l.HandleUserMessage(context.Background(), msg, working, nil); err != nil {
+		t.Fatal(err)
+	}
+	if sent := l.GetMessages()[0].Content; strings.Contains(sent, "AKIA") {
+		t.Errorf("sent message %q contains the secret", sent)
+	}
+	if got, _ := l.LastUserMessage(); got != msg {
+		t.Errorf("LastUserMessage() = %q, want %q", got, msg)
+	}
+}
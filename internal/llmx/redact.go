@@ -0,0 +1,60 @@
+package llmx
+
+import (
+	"path/filepath"
+
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/codebase"
+	"github.com/thxrsxm/harzmind-code/internal/common"
+	"github.com/thxrsxm/harzmind-code/internal/logger"
+	"github.com/thxrsxm/harzmind-code/internal/output"
+	"github.com/thxrsxm/harzmind-code/internal/secrets"
+	"github.com/thxrsxm/rnbw"
+)
+
+// SOURCE_MESSAGE is the source of the secrets found in a user message.
+const SOURCE_MESSAGE string = "message"
+
+// loadAllowlist reloads the allowlist of the secret scanner, so that changes apply to the next turn.
+func (l *LLMx) loadAllowlist() {
+	allow, err := secrets.LoadAllowlist(common.PATH_FILE_SECRETS_ALLOWLIST)
+	if err != nil {
+		output.PrintfWarning("reading secrets allowlist: %v\n", err)
+		logger.Log(logger.ERROR, "reading secrets allowlist: %v", err)
+	}
+	l.redactor.SetAllowlist(allow)
+}
+
+// redactFiles returns a copy of the files with the secrets replaced by placeholders.
+func (l *LLMx) redactFiles(files []codebase.File) ([]codebase.File, []secrets.Finding) {
+	redacted := make([]codebase.File, len(files))
+	findings := []secrets.Finding{}
+	for i, f := range files {
+		content, found := l.redactor.Redact(filepath.ToSlash(f.Path), f.Content)
+		redacted[i] = codebase.File{Name: f.Name, Content: content, Path: f.Path}
+		findings = append(findings, found...)
+	}
+	return redacted, findings
+}
+
+// reportRedactions prints and logs the secrets that were replaced before sending.
+func reportRedactions(findings []secrets.Finding) {
+	if len(findings) == 0 {
+		return
+	}
+	summary := secrets.Summary(findings)
+	rnbw.ForegroundColor(rnbw.Gray)
+	output.Printf("Redacted %s\n", summary)
+	rnbw.ResetColor()
+	logger.Log(logger.INFO, "redacted %s", summary)
+}
+
+// reservePlaceholders keeps new secrets from getting the placeholders used in restored messages.
+func (l *LLMx) reservePlaceholders(messages []api.Message) {
+	for _, m := range messages {
+		l.redactor.Reserve(m.Content)
+		for _, call := range m.ToolCalls {
+			l.redactor.Reserve(call.Function.Arguments)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package llmx
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/thxrsxm/harzmind-code/internal/codebase"
+	"github.com/thxrsxm/harzmind-code/internal/edits"
+	"github.com/thxrsxm/harzmind-code/internal/executor"
+	"github.com/thxrsxm/harzmind-code/internal/logger"
+	"github.com/thxrsxm/harzmind-code/internal/output"
+	"github.com/thxrsxm/harzmind-code/internal/policy"
+	"github.com/thxrsxm/rnbw"
+)
+
+// maxDirBytes is the maximum size of the files attached by a directory reference.
+const maxDirBytes int = 256 * 1024
+
+// Reference kinds.
+const (
+	refFile    string = "file"
+	refDir     string = "dir"
+	refCommand string = "command"
+)
+
+var (
+	// pathRefPattern matches `@path`, `@path:10-40` and `@dir/` after whitespace or at the start.
+	pathRefPattern *regexp.Regexp = regexp.MustCompile("(^|\\s)@([^\\s`]+)")
+	// lineRangePattern matches the line range suffix of a file reference.
+	lineRangePattern *regexp.Regexp = regexp.MustCompile(`^(.+):(\d+)(?:-(\d+))?$`)
+	// quotedCommandPattern matches !`command` anywhere.
+	quotedCommandPattern *regexp.Regexp = regexp.MustCompile("!`([^`]+)`")
+	// lineCommandPattern matches a line starting with `!command`, but not with `!!` or an image `![alt](url)`.
+	lineCommandPattern *regexp.Regexp = regexp.MustCompile(`(?m)^!([^\s!\[` + "`" + `].*)$`)
+)
+
+// reference is an inline reference of a user message.
+type reference struct {
+	kind  string
+	path  string
+	start int
+	end   int
+	// command is the shell command of a command reference.
+	command string
+}
+
+// parseReferences finds the references of a message in order of appearance.
+// References in fenced code blocks and in inline code spans are ignored,
+// except for the command of a !`command` reference.
+func parseReferences(msg string) []reference {
+	text := maskCode(msg)
+	type found struct {
+		pos int
+		ref reference
+	}
+	all := []found{}
+	for _, m := range quotedCommandPattern.FindAllStringSubmatchIndex(msg, -1) {
+		// The command is masked as code span, the `!` before it must be outside of code
+		if text[m[0]] == '!' {
+			all = append(all, found{m[0], reference{kind: refCommand, command: strings.TrimSpace(msg[m[2]:m[3]])}})
+		}
+	}
+	for _, m := range lineCommandPattern.FindAllStringSubmatchIndex(text, -1) {
+		command := strings.TrimSpace(msg[m[2]:m[3]])
+		// Prose such as `!important` is no command
+		if _, err := exec.LookPath(strings.Fields(command)[0]); err != nil {
+			continue
+		}
+		all = append(all, found{m[0], reference{kind: refCommand, command: command}})
+		// The rest of the line belongs to the command
+		text = text[:m[0]] + strings.Repeat(" ", m[1]-m[0]) + text[m[1]:]
+	}
+	for _, m := range pathRefPattern.FindAllStringSubmatchIndex(text, -1) {
+		token := strings.TrimRight(msg[m[4]:m[5]], ".,;:!?)]}'\"")
+		if len(token) == 0 {
+			continue
+		}
+		all = append(all, found{m[4], parsePathReference(token)})
+	}
+	slices.SortFunc(all, func(a, b found) int { return cmp.Compare(a.pos, b.pos) })
+	refs := make([]reference, 0, len(all))
+	for _, f := range all {
+		refs = append(refs, f.ref)
+	}
+	return refs
+}
+
+// parsePathReference parses the token of a file or directory reference.
+func parsePathReference(token string) reference {
+	if strings.HasSuffix(token, "/") {
+		return reference{kind: refDir, path: strings.TrimSuffix(token, "/")}
+	}
+	if m := lineRangePattern.FindStringSubmatch(token); m != nil {
+		start, _ := strconv.Atoi(m[2])
+		end := start
+		if len(m[3]) > 0 {
+			end, _ = strconv.Atoi(m[3])
+		}
+		return reference{kind: refFile, path: m[1], start: start, end: end}
+	}
+	return reference{kind: refFile, path: token}
+}
+
+// maskCode replaces the content of fenced code blocks and inline code spans with spaces,
+// keeping the positions of all other characters.
+func maskCode(msg string) string {
+	b := []byte(msg)
+	inFence := false
+	lineStart := 0
+	for lineStart <= len(b) {
+		lineEnd := strings.IndexByte(msg[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(b)
+		} else {
+			lineEnd += lineStart
+		}
+		line := strings.TrimSpace(msg[lineStart:lineEnd])
+		isFence := strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")
+		if inFence || isFence {
+			for i := lineStart; i < lineEnd; i++ {
+				b[i] = ' '
+			}
+			if isFence {
+				inFence = !inFence
+			}
+		} else {
+			// Mask inline code spans
+			open := -1
+			for i := lineStart; i < lineEnd; i++ {
+				if b[i] != '`' {
+					continue
+				}
+				if open < 0 {
+					open = i
+				} else {
+					for j := open; j <= i; j++ {
+						b[j] = ' '
+					}
+					open = -1
+				}
+			}
+		}
+		lineStart = lineEnd + 1
+	}
+	return string(b)
+}
+
+// expandReferences resolves the references of the message into attachments.
+// References that cannot be resolved (missing, ignored or outside of the project) and commands
+// denied by the command policy are reported as warnings and skipped.
+func (l *LLMx) expandReferences(ctx context.Context, msg string) ([]attachment, error) {
+	attachments := []attachment{}
+	for _, ref := range parseReferences(msg) {
+		var resolved []attachment
+		var err error
+		switch ref.kind {
+		case refFile:
+			resolved, err = fileAttachment(ref)
+		case refDir:
+			resolved, err = dirAttachments(ref)
+		case refCommand:
+			resolved, err = l.commandAttachment(ctx, ref)
+			// Only denied commands are skipped, a failing prompt or a cancellation aborts the message
+			var denied *policy.DeniedError
+			if err != nil && !errors.As(err, &denied) {
+				return nil, err
+			}
+		}
+		if err != nil {
+			output.PrintfWarning("skipping reference: %v\n", err)
+			logger.Log(logger.WARNING, "skipping reference: %v", err)
+			continue
+		}
+		for _, a := range resolved {
+			rnbw.ForegroundColor(rnbw.Gray)
+			output.Printf("Attached %s\n", a.label)
+			rnbw.ResetColor()
+		}
+		attachments = append(attachments, resolved...)
+	}
+	return attachments, nil
+}
+
+// fileAttachment reads the file (or its line range) of a reference.
+// Binary files and files beyond the file limit of the codebase are skipped (see codebase.ReadFile).
+func fileAttachment(ref reference) ([]attachment, error) {
+	path, err := edits.ValidatePath(ref.path)
+	if err != nil {
+		return nil, err
+	}
+	f, skip, err := codebase.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if skip != nil {
+		return nil, fmt.Errorf("%s is %s (%d bytes)", ref.path, skip.Reason, skip.Size)
+	}
+	label := fmt.Sprintf("File `%s`", filepath.ToSlash(path))
+	content := f.Content
+	if ref.start > 0 {
+		lines := strings.SplitAfter(strings.TrimSuffix(content, "\n"), "\n")
+		end := min(ref.end, len(lines))
+		if ref.start > end {
+			return nil, fmt.Errorf("invalid line range %d-%d of %s (%d lines)", ref.start, ref.end, ref.path, len(lines))
+		}
+		content = strings.Join(lines[ref.start-1:end], "") + "\n"
+		label += fmt.Sprintf(" (lines %d-%d)", ref.start, end)
+	}
+	return []attachment{{label: label, content: content}}, nil
+}
+
+// dirAttachments reads the files of the codebase below the directory of a reference.
+// The files are attached until maxDirBytes is reached.
+func dirAttachments(ref reference) ([]attachment, error) {
+	dir, err := edits.ValidatePath(ref.path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", ref.path)
+	}
+	files, err := codebase.GetCodeBase(dir)
+	if err != nil {
+		return nil, err
+	}
+	attachments := []attachment{}
+	size := 0
+	for _, f := range files {
+		if size+len(f.Content) > maxDirBytes {
+			output.PrintfWarning("attached %d of %d files of %s (limit of %d bytes)\n", len(attachments), len(files), ref.path, maxDirBytes)
+			break
+		}
+		size += len(f.Content)
+		attachments = append(attachments, attachment{label: fmt.Sprintf("File `%s`", filepath.ToSlash(f.Path)), content: f.Content})
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files in %s", ref.path)
+	}
+	return attachments, nil
+}
+
+// commandAttachment runs the command of a reference with the command policy and sandbox of the tools.
+func (l *LLMx) commandAttachment(ctx context.Context, ref reference) ([]attachment, error) {
+	if err := l.tools.Commands.Confirm(ref.command); err != nil {
+		return nil, err
+	}
+	res, err := executor.Run(ctx, ref.command, l.tools.Exec)
+	policy.Record("reference", ref.command, res.ExitCode, err)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	label := fmt.Sprintf("Output of `%s` (%s)", ref.command, strings.ReplaceAll(res.Report(), "\n", ", "))
+	return []attachment{{label: label, content: res.Output}}, nil
+}
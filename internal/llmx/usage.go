@@ -0,0 +1,122 @@
+package llmx
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkoukk/tiktoken-go"
+
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/modelinfo"
+)
+
+// Turn records the token usage of a single answered user message.
+type Turn struct {
+	Model string    `json:"model"`
+	Usage api.Usage `json:"usage"`
+	// Estimated is true if the API reported no usage and the tokens were counted locally.
+	Estimated bool `json:"estimated,omitempty"`
+	// Summary is true if the turn summarized the conversation (see LLMx.Compact).
+	Summary bool `json:"summary,omitempty"`
+}
+
+// GetTurns returns the usage of all turns of the session, including cleared ones.
+func (l *LLMx) GetTurns() []Turn {
+	return l.turns
+}
+
+// TotalUsage returns the sum of the token usage of all turns.
+func TotalUsage(turns []Turn) api.Usage {
+	var total api.Usage
+	for _, t := range turns {
+		total = total.Add(t.Usage)
+	}
+	return total
+}
+
+// newTurn creates the turn of a request and its response.
+// If the API reported no usage, the tokens are estimated with tiktoken.
+func newTurn(model string, usage *api.Usage, request []api.Message, response string) Turn {
+	turn := Turn{Model: model}
+	if usage != nil {
+		turn.Usage = *usage
+	} else {
+		encoding := encodingForModel(model)
+		turn.Usage = api.Usage{
+			PromptTokens:     countTokens(encoding, request),
+			CompletionTokens: countText(encoding, response),
+		}
+		turn.Estimated = true
+	}
+	return turn
+}
+
+// encodingForModel returns the model-specific tokenizer.
+// It falls back to cl100k_base (GPT-4 encoding) for unknown models.
+// The result is nil if the encoding cannot be loaded (e.g. offline on first use).
+func encodingForModel(model string) *tiktoken.Tiktoken {
+	encoding, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		encoding, _ = tiktoken.GetEncoding("cl100k_base")
+	}
+	return encoding
+}
+
+// countTokens counts the tokens of the messages (including the arguments of tool calls) with the tokenizer.
+func countTokens(encoding *tiktoken.Tiktoken, messages []api.Message) int {
+	count := 0
+	for _, v := range messages {
+		count += countText(encoding, v.Content)
+		for _, call := range v.ToolCalls {
+			count += countText(encoding, call.Function.Arguments)
+		}
+	}
+	return count
+}
+
+// countText counts the tokens of the text with the tokenizer.
+// Without a tokenizer, the count is estimated as one token per four bytes.
+func countText(encoding *tiktoken.Tiktoken, text string) int {
+	if encoding == nil {
+		return (len(text) + 3) / 4
+	}
+	return len(encoding.Encode(text, nil, nil))
+}
+
+// CostReport formats the usage and cost of every turn and the session totals as a table.
+// Prices are looked up per model, custom prices take precedence over the built-in ones.
+// Estimated token counts are marked with '~', unknown costs are shown as '-'.
+func CostReport(turns []Turn, prices map[string]modelinfo.Price, currency string) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Turn\tModel\tPrompt\tCached\tCompletion\tCost\t")
+	totalCost := 0.0
+	unknown := false
+	for i, t := range turns {
+		mark := ""
+		if t.Estimated {
+			mark = "~"
+		}
+		cost := "-"
+		if price, ok := modelinfo.LookupPrice(t.Model, prices); ok {
+			c := price.Cost(t.Usage)
+			totalCost += c
+			cost = fmt.Sprintf("%s%.4f %s", mark, c, currency)
+		} else {
+			unknown = true
+		}
+		model := t.Model
+		if t.Summary {
+			model += " (summary)"
+		}
+		fmt.Fprintf(w, "#%d\t%s\t%s%d\t%d\t%s%d\t%s\t\n", i+1, model, mark, t.Usage.PromptTokens, t.Usage.CachedTokens, mark, t.Usage.CompletionTokens, cost)
+	}
+	total := TotalUsage(turns)
+	fmt.Fprintf(w, "Total\t\t%d\t%d\t%d\t%.4f %s\t\n", total.PromptTokens, total.CachedTokens, total.CompletionTokens, totalCost, currency)
+	w.Flush()
+	if unknown {
+		sb.WriteString("Turns marked with '-' have no known price and are not included in the total\n")
+	}
+	return sb.String()
+}
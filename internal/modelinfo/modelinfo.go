@@ -0,0 +1,38 @@
+// Package modelinfo provides static metadata about well-known LLM models,
+// such as token prices, and lookups that tolerate dated or prefixed model names.
+package modelinfo
+
+import (
+	"regexp"
+	"strings"
+)
+
+// versionSuffix matches what may follow the name of a model family in the name of one of its versions:
+// dates and version numbers (e.g. `-2024-08-06`, `-20250929`, `-0613`), `-latest`, `-preview`
+// and Ollama tags (e.g. `:3b`).
+var versionSuffix *regexp.Regexp = regexp.MustCompile(`^(-(\d+|latest|preview))*(:\S+)?$`)
+
+// lookup finds the entry for a model in the table.
+// An exact match wins, otherwise the longest key that prefixes the model name is used if the rest of
+// the name is a version (see versionSuffix), so that dated versions (e.g. `gpt-4o-2024-08-06`) resolve
+// to their family while sibling models (e.g. `o3-mini` of `o3`) are unknown.
+// Keys ending in `-` (e.g. `claude-`) name families whose models share the entry and match any rest.
+// Path-like prefixes (e.g. `models/` or `openai/`) are ignored.
+func lookup[T any](table map[string]T, model string) (T, bool) {
+	model = strings.ToLower(model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	if v, ok := table[model]; ok {
+		return v, true
+	}
+	var best T
+	bestLen := 0
+	for key, v := range table {
+		if len(key) > bestLen && strings.HasPrefix(model, key) && (strings.HasSuffix(key, "-") || versionSuffix.MatchString(model[len(key):])) {
+			best = v
+			bestLen = len(key)
+		}
+	}
+	return best, bestLen > 0
+}
@@ -0,0 +1,58 @@
+package modelinfo
+
+import (
+	"maps"
+
+	"github.com/thxrsxm/harzmind-code/internal/api"
+)
+
+// Price is the price of a model in currency units per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+	// CachedInput is the price of input tokens read from the prompt cache (0 uses Input).
+	CachedInput float64 `json:"cachedInput,omitempty"`
+}
+
+// defaultPrices contains the list prices in USD of well-known models.
+var defaultPrices map[string]Price = map[string]Price{
+	"gpt-4-turbo":           {Input: 10, Output: 30},
+	"gpt-4o":                {Input: 2.5, Output: 10, CachedInput: 1.25},
+	"gpt-4o-mini":           {Input: 0.15, Output: 0.6, CachedInput: 0.075},
+	"gpt-4.1":               {Input: 2, Output: 8, CachedInput: 0.5},
+	"gpt-4.1-mini":          {Input: 0.4, Output: 1.6, CachedInput: 0.1},
+	"gpt-4.1-nano":          {Input: 0.1, Output: 0.4, CachedInput: 0.025},
+	"gpt-5":                 {Input: 1.25, Output: 10, CachedInput: 0.125},
+	"gpt-5-mini":            {Input: 0.25, Output: 2, CachedInput: 0.025},
+	"gpt-5-nano":            {Input: 0.05, Output: 0.4, CachedInput: 0.005},
+	"o3":                    {Input: 2, Output: 8, CachedInput: 0.5},
+	"o3-mini":               {Input: 1.1, Output: 4.4, CachedInput: 0.55},
+	"o4-mini":               {Input: 1.1, Output: 4.4, CachedInput: 0.275},
+	"claude-3-5-haiku":      {Input: 0.8, Output: 4, CachedInput: 0.08},
+	"claude-haiku-4-5":      {Input: 1, Output: 5, CachedInput: 0.1},
+	"claude-sonnet-4":       {Input: 3, Output: 15, CachedInput: 0.3},
+	"claude-opus-4":         {Input: 15, Output: 75, CachedInput: 1.5},
+	"claude-opus-4-5":       {Input: 5, Output: 25, CachedInput: 0.5},
+	"gemini-2.0-flash":      {Input: 0.1, Output: 0.4, CachedInput: 0.025},
+	"gemini-2.5-flash":      {Input: 0.3, Output: 2.5, CachedInput: 0.075},
+	"gemini-2.5-pro":        {Input: 1.25, Output: 10, CachedInput: 0.31},
+	"gemini-2.5-flash-lite": {Input: 0.1, Output: 0.4, CachedInput: 0.025},
+}
+
+// LookupPrice returns the price of a model.
+// Prices configured by the user take precedence over the built-in table.
+func LookupPrice(model string, custom map[string]Price) (Price, bool) {
+	prices := maps.Clone(defaultPrices)
+	maps.Copy(prices, custom)
+	return lookup(prices, model)
+}
+
+// Cost returns the cost of the token usage at this price.
+func (p Price) Cost(usage api.Usage) float64 {
+	cachedPrice := p.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = p.Input
+	}
+	uncached := usage.PromptTokens - usage.CachedTokens
+	return (float64(uncached)*p.Input + float64(usage.CachedTokens)*cachedPrice + float64(usage.CompletionTokens)*p.Output) / 1_000_000
+}
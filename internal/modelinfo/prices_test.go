@@ -0,0 +1,63 @@
+package modelinfo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/thxrsxm/harzmind-code/internal/api"
+)
+
+func TestLookupPrice(t *testing.T) {
+	custom := map[string]Price{
+		"my-model": {Input: 1, Output: 2},
+		"gpt-4o":   {Input: 3, Output: 4},
+	}
+	tests := []struct {
+		name  string
+		model string
+		want  Price
+		found bool
+	}{
+		{"Exact match", "gpt-4o-mini", defaultPrices["gpt-4o-mini"], true},
+		{"Dated version", "claude-sonnet-4-5-20250929", defaultPrices["claude-sonnet-4"], true},
+		{"Longest prefix", "claude-opus-4-5-20251101", defaultPrices["claude-opus-4-5"], true},
+		{"Path prefix", "models/gemini-2.5-pro", defaultPrices["gemini-2.5-pro"], true},
+		{"Custom price", "my-model", custom["my-model"], true},
+		{"Custom overrides default", "gpt-4o-2024-08-06", custom["gpt-4o"], true},
+		{"Latest alias", "gpt-4o-latest", custom["gpt-4o"], true},
+		{"Sibling model", "o3-mini", defaultPrices["o3-mini"], true},
+		{"Dated sibling model", "o3-mini-2025-01-31", defaultPrices["o3-mini"], true},
+		{"Pro model", "o3-pro", Price{}, false},
+		{"Pro model of family", "gpt-5-pro", Price{}, false},
+		{"Unknown model", "llama3.2:3b", Price{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := LookupPrice(tt.model, custom)
+			if got != tt.want || found != tt.found {
+				t.Errorf("LookupPrice(%s) = %v, %v, want %v, %v", tt.model, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestCost(t *testing.T) {
+	usage := api.Usage{PromptTokens: 1_000_000, CompletionTokens: 500_000, CachedTokens: 400_000}
+	tests := []struct {
+		name  string
+		price Price
+		want  float64
+	}{
+		{"Cached price", Price{Input: 2, Output: 8, CachedInput: 0.5}, 0.6*2 + 0.4*0.5 + 0.5*8},
+		{"No cached price", Price{Input: 2, Output: 8}, 2 + 0.5*8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.price.Cost(usage); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Cost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
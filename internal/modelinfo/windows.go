@@ -0,0 +1,43 @@
+package modelinfo
+
+// contextWindows contains the context window in tokens of well-known models.
+var contextWindows map[string]int = map[string]int{
+	"gpt-3.5-turbo":     16385,
+	"gpt-4":             8192,
+	"gpt-4-32k":         32768,
+	"gpt-4-turbo":       128000,
+	"gpt-4o":            128000,
+	"gpt-4o-mini":       128000,
+	"gpt-4.1":           1047576,
+	"gpt-4.1-mini":      1047576,
+	"gpt-4.1-nano":      1047576,
+	"gpt-4.5":           128000,
+	"gpt-5":             400000,
+	"gpt-5-mini":        400000,
+	"gpt-5-nano":        400000,
+	"gpt-5-pro":         400000,
+	"o1":                200000,
+	"o1-mini":           128000,
+	"o3":                200000,
+	"o3-mini":           200000,
+	"o3-pro":            200000,
+	"o4-mini":           200000,
+	"claude-":           200000,
+	"gemini-1.5-flash":  1048576,
+	"gemini-1.5-pro":    2097152,
+	"gemini-2.0-":       1048576,
+	"gemini-2.5-":       1048576,
+	"mistral-large":     131072,
+	"codestral":         256000,
+	"deepseek-chat":     128000,
+	"deepseek-reasoner": 128000,
+	"llama3.1":          131072,
+	"llama3.2":          131072,
+	"llama3.3":          131072,
+	"qwen2.5-coder":     32768,
+}
+
+// LookupContextWindow returns the context window of a model in tokens.
+func LookupContextWindow(model string) (int, bool) {
+	return lookup(contextWindows, model)
+}
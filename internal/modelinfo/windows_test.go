@@ -0,0 +1,31 @@
+package modelinfo
+
+import "testing"
+
+func TestLookupContextWindow(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+		want  int
+		found bool
+	}{
+		{"Model family", "claude-sonnet-4-5", 200000, true},
+		{"Longer prefix wins", "gpt-4o-mini", 128000, true},
+		{"Short prefix", "gpt-4-0613", 8192, true},
+		{"Sibling of short prefix", "gpt-4-32k", 32768, true},
+		{"Preview of sibling", "gpt-4.5-preview", 128000, true},
+		{"Unknown sibling", "gpt-4-vision", 0, false},
+		{"Gemini family", "gemini-2.5-flash-lite", 1048576, true},
+		{"Ollama tag", "llama3.2:3b", 131072, true},
+		{"Unknown model", "my-model", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := LookupContextWindow(tt.model)
+			if got != tt.want || found != tt.found {
+				t.Errorf("LookupContextWindow(%s) = %d, %v, want %d, %v", tt.model, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
@@ -131,6 +131,19 @@ func Printf(format string, a ...any) {
 	}
 }
 
+// printWriter is an io.Writer that prints to the current output targets.
+type printWriter struct{}
+
+func (printWriter) Write(p []byte) (int, error) {
+	Print(string(p))
+	return len(p), nil
+}
+
+// Writer returns an io.Writer that prints to the current output targets (e.g. for streaming command output).
+func Writer() io.Writer {
+	return printWriter{}
+}
+
 // PrintWarning prints a warning message with color styling to yellow.
 func PrintWarning(a ...any) {
 	rnbw.ForegroundColor(rnbw.Yellow)
@@ -0,0 +1,416 @@
+// Package policy decides whether shell commands may be executed.
+//
+// A policy consists of allow, ask and deny rules whose patterns are matched against
+// the command, where `*` matches any sequence of characters. Compound commands
+// (e.g. `go vet ./... && git push`) are split into their parts and every part is
+// checked; the most restrictive action wins. Commands with command substitution
+// or with output redirected to a file outside of the project are never allowed
+// without asking. Commands that are neither allowed nor denied are confirmed
+// interactively, and every decision and execution is logged as an audit trail.
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/thxrsxm/harzmind-code/internal/input"
+	"github.com/thxrsxm/harzmind-code/internal/logger"
+	"github.com/thxrsxm/harzmind-code/internal/output"
+)
+
+// Action is the decision of a rule.
+type Action string
+
+const (
+	// ALLOW runs the command without confirmation.
+	ALLOW Action = "allow"
+	// ASK asks the user before the command is run.
+	ASK Action = "ask"
+	// DENY never runs the command.
+	DENY Action = "deny"
+)
+
+// Rule applies an action to the commands matching the pattern.
+type Rule struct {
+	Pattern string `json:"pattern"`
+	Action  Action `json:"action"`
+}
+
+// Policy is a list of rules and the action for commands that match no rule.
+type Policy struct {
+	// Default is the action for commands without a matching rule (empty means ASK).
+	Default Action `json:"default,omitempty"`
+	Rules   []Rule `json:"rules"`
+	// TrustProject lets the allow rules of the project's policy take effect.
+	// It is only read from the global policy.
+	TrustProject bool `json:"trustProject,omitempty"`
+}
+
+// DeniedError is returned for a command that was denied by a rule or by the user.
+type DeniedError struct {
+	Command string
+	// Rule is the pattern of the denying rule, empty if the user denied the command.
+	Rule string
+}
+
+func (e *DeniedError) Error() string {
+	if len(e.Rule) == 0 {
+		return fmt.Sprintf("command '%s' was denied by the user", e.Command)
+	}
+	return fmt.Sprintf("command '%s' is denied by the rule '%s'", e.Command, e.Rule)
+}
+
+// Checker checks commands against the merged policies and remembers
+// the commands the user allowed for the rest of the session.
+type Checker struct {
+	rules        []Rule
+	defaultRule  Action
+	allowSession map[string]bool
+}
+
+// LoadFile reads a policy from a JSON file. A missing file results in an empty policy.
+func LoadFile(path string) (Policy, error) {
+	var p Policy
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("invalid policy %s: %w", path, err)
+	}
+	return p, p.Validate()
+}
+
+// Validate checks that all actions of the policy are known.
+func (p Policy) Validate() error {
+	if err := validAction(p.Default, true); err != nil {
+		return err
+	}
+	for _, r := range p.Rules {
+		if len(strings.TrimSpace(r.Pattern)) == 0 {
+			return fmt.Errorf("rule without pattern")
+		}
+		if err := validAction(r.Action, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewChecker creates a checker for the rules of all policies.
+// The default action is the most restrictive default of the policies.
+func NewChecker(policies ...Policy) *Checker {
+	c := &Checker{defaultRule: ALLOW, allowSession: map[string]bool{}}
+	hasDefault := false
+	for _, p := range policies {
+		c.rules = append(c.rules, p.Rules...)
+		if len(p.Default) > 0 {
+			c.defaultRule = strictest(c.defaultRule, p.Default)
+			hasDefault = true
+		}
+	}
+	if !hasDefault {
+		c.defaultRule = ASK
+	}
+	return c
+}
+
+// NewProjectChecker creates a checker for the global policy and the policy of the project.
+// Unless the global policy trusts the project, the project can only tighten the global policy:
+// its allow rules and an allow default are ignored, so a cloned repository cannot pre-approve commands.
+func NewProjectChecker(global, project Policy) *Checker {
+	if !global.TrustProject {
+		project = project.withoutAllow()
+	}
+	return NewChecker(global, project)
+}
+
+// withoutAllow returns the policy without its allow rules and without an allow default.
+func (p Policy) withoutAllow() Policy {
+	restricted := Policy{Rules: []Rule{}}
+	if p.Default != ALLOW {
+		restricted.Default = p.Default
+	} else {
+		logger.Log(logger.WARNING, "ignoring the allow default of the project's command policy")
+	}
+	for _, r := range p.Rules {
+		if r.Action == ALLOW {
+			logger.Log(logger.WARNING, "ignoring the allow rule '%s' of the project's command policy", r.Pattern)
+			continue
+		}
+		restricted.Rules = append(restricted.Rules, r)
+	}
+	return restricted
+}
+
+// Evaluate returns the action for the command and the pattern of the deciding rule
+// (empty if the default action applies). A command without any part gets the default action.
+func (c *Checker) Evaluate(command string) (Action, string) {
+	parts := splitCommand(command)
+	if len(parts) == 0 {
+		return c.defaultRule, ""
+	}
+	action, rule := ALLOW, ""
+	for i, part := range parts {
+		a, r := c.defaultRule, ""
+		matched := false
+		for _, rr := range c.rules {
+			if !match(rr.Pattern, part) {
+				continue
+			}
+			if !matched || strictest(a, rr.Action) != a {
+				a, r = rr.Action, rr.Pattern
+			}
+			matched = true
+		}
+		// Allow rules do not cover writing files outside of the project
+		if a == ALLOW && redirectsOutside(part) {
+			a, r = ASK, ""
+		}
+		if i == 0 || strictest(action, a) != action {
+			action, rule = a, r
+		}
+	}
+	// Allow rules do not cover commands hidden in a substitution
+	if action == ALLOW && (strings.Contains(command, "$(") || strings.Contains(command, "`")) {
+		return ASK, ""
+	}
+	return action, rule
+}
+
+// Confirm decides whether the command may run. Denied commands result in a DeniedError.
+// For commands that need confirmation, the user chooses to run the command once,
+// to allow it for the rest of the session, or to deny it.
+func (c *Checker) Confirm(command string) error {
+	action, rule := c.Evaluate(command)
+	key := normalize(command)
+	switch {
+	case action == DENY:
+		logger.Log(logger.WARNING, "audit: denied command '%s' (rule '%s')", command, rule)
+		return &DeniedError{Command: command, Rule: rule}
+	case action == ALLOW:
+		logger.Log(logger.INFO, "audit: allowed command '%s' (rule '%s')", command, rule)
+		return nil
+	case c.allowSession[key]:
+		logger.Log(logger.INFO, "audit: allowed command '%s' (this session)", command)
+		return nil
+	}
+	output.Printf("Run `%s`? [o]nce, [a]lways this session, [d]eny: ", command)
+	answer, err := input.ReadInput(true)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(answer) {
+	case "o", "once", "y", "yes":
+		logger.Log(logger.INFO, "audit: user allowed command '%s' once", command)
+		return nil
+	case "a", "always":
+		c.allowSession[key] = true
+		logger.Log(logger.INFO, "audit: user allowed command '%s' for this session", command)
+		return nil
+	default:
+		logger.Log(logger.INFO, "audit: user denied command '%s'", command)
+		return &DeniedError{Command: command}
+	}
+}
+
+// Record logs the execution of a command with its exit code,
+// or the error if the command could not be run.
+func Record(source, command string, exitCode int, err error) {
+	if err != nil {
+		logger.Log(logger.WARNING, "audit: %s failed to execute '%s': %v", source, command, err)
+		return
+	}
+	logger.Log(logger.INFO, "audit: %s executed '%s' (exit code %d)", source, command, exitCode)
+}
+
+// match reports whether the command matches the pattern, `*` matches any sequence of characters.
+// Whitespace is normalized in both.
+func match(pattern, command string) bool {
+	parts := strings.Split(normalize(pattern), "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	re, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(normalize(command))
+}
+
+// normalize collapses all whitespace to single spaces.
+func normalize(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// splitCommand splits a compound command at `;`, `&&`, `||`, `|`, `&` and newlines outside of quotes.
+// The `&` of the redirections `>&`, `<&` and `&>` (e.g. `2>&1`) does not split the command.
+func splitCommand(command string) []string {
+	parts := []string{}
+	var sb strings.Builder
+	var quote rune
+	escaped := false
+	flush := func() {
+		if part := strings.TrimSpace(sb.String()); len(part) > 0 {
+			parts = append(parts, part)
+		}
+		sb.Reset()
+	}
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '&' && i > 0 && (runes[i-1] == '>' || runes[i-1] == '<'):
+		case r == '&' && i+1 < len(runes) && runes[i+1] == '>':
+		case r == '|' && i > 0 && runes[i-1] == '>':
+		case r == ';' || r == '\n':
+			flush()
+			continue
+		case r == '&' || r == '|':
+			// `&&`, `||` and `|&` are single operators
+			if i+1 < len(runes) && (runes[i+1] == '&' || runes[i+1] == '|') {
+				i++
+			}
+			flush()
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	flush()
+	return parts
+}
+
+// devices are the files outside of the project that output may be redirected to.
+var devices []string = []string{"/dev/null", "/dev/stdout", "/dev/stderr"}
+
+// redirectsOutside reports whether a part of a command redirects output with `>`, `>>`, `>|` or `&>`
+// to a file that may be outside of the project: an absolute path, a path leading out with `..`,
+// or a path with `~` or variables that cannot be resolved. Duplications such as `2>&1` are no files.
+func redirectsOutside(part string) bool {
+	for _, target := range redirectTargets(part) {
+		if slices.Contains(devices, target) {
+			continue
+		}
+		if strings.HasPrefix(target, "~") || strings.Contains(target, "$") || filepath.IsAbs(target) || strings.HasPrefix(target, "/") {
+			return true
+		}
+		clean := filepath.Clean(filepath.FromSlash(target))
+		if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectTargets returns the unquoted targets of the output redirections of a part of a command.
+func redirectTargets(part string) []string {
+	targets := []string{}
+	runes := []rune(part)
+	var quote rune
+	escaped := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case escaped:
+			escaped = false
+			continue
+		case r == '\\' && quote != '\'':
+			escaped = true
+			continue
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			continue
+		case r == '\'' || r == '"':
+			quote = r
+			continue
+		case r != '>':
+			continue
+		}
+		// Skip the rest of the operator
+		if i+1 < len(runes) && (runes[i+1] == '>' || runes[i+1] == '|') {
+			i++
+		}
+		if i+1 < len(runes) && runes[i+1] == '&' {
+			i++
+			// `>&1` and `>&-` duplicate or close a file descriptor
+			if i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '-') {
+				continue
+			}
+		}
+		for i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+			i++
+		}
+		// Read the target word and remove its quotes
+		var sb strings.Builder
+		for i+1 < len(runes) {
+			c := runes[i+1]
+			if quote == 0 && !escaped && (unicode.IsSpace(c) || strings.ContainsRune("<>&|;", c)) {
+				break
+			}
+			i++
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\' && quote != '\'':
+				escaped = true
+				continue
+			case quote != 0 && c == quote:
+				quote = 0
+				continue
+			case quote == 0 && (c == '\'' || c == '"'):
+				quote = c
+				continue
+			}
+			sb.WriteRune(c)
+		}
+		if sb.Len() > 0 {
+			targets = append(targets, sb.String())
+		}
+	}
+	return targets
+}
+
+// strictest returns the more restrictive of both actions.
+func strictest(a, b Action) Action {
+	rank := map[Action]int{ALLOW: 0, ASK: 1, DENY: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// validAction checks that the action is known, empty is only valid as default.
+func validAction(a Action, optional bool) error {
+	switch a {
+	case ALLOW, ASK, DENY:
+		return nil
+	case "":
+		if optional {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown action '%s' (allow, ask, deny)", a)
+}
@@ -0,0 +1,160 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	global := Policy{Rules: []Rule{
+		{Pattern: "go test ./...", Action: ALLOW},
+		{Pattern: "go vet *", Action: ALLOW},
+		{Pattern: "rm -rf *", Action: DENY},
+	}}
+	project := Policy{Rules: []Rule{
+		{Pattern: "git push*", Action: ASK},
+		{Pattern: "git *", Action: ALLOW},
+	}}
+	c := NewChecker(global, project)
+	tests := []struct {
+		command    string
+		wantAction Action
+		wantRule   string
+	}{
+		{"go test ./...", ALLOW, "go test ./..."},
+		{"go  test   ./...", ALLOW, "go test ./..."},
+		{"go test ./... -v", ASK, ""},
+		{"go vet ./internal/...", ALLOW, "go vet *"},
+		{"rm -rf /", DENY, "rm -rf *"},
+		{"go test ./... && rm -rf build", DENY, "rm -rf *"},
+		{"git status", ALLOW, "git *"},
+		{"git push origin main", ASK, "git push*"},
+		{"echo 'a && rm -rf /'", ASK, ""},
+		{"go vet $(rm -rf /)", ASK, ""},
+		{"make", ASK, ""},
+		{"", ASK, ""},
+		{"   ", ASK, ""},
+		{"; &&", ASK, ""},
+		{"go vet ./... 2>&1", ALLOW, "go vet *"},
+		{"go vet ./... 2>&1 | rm -rf build", DENY, "rm -rf *"},
+		{"go vet ./... > vet.log", ALLOW, "go vet *"},
+		{"go vet ./... >> build/vet.log 2>/dev/null", ALLOW, "go vet *"},
+		{"go vet ./... > /tmp/vet.log", ASK, ""},
+		{"go vet ./... &> ../vet.log", ASK, ""},
+		{"go vet ./... 2>>~/.bashrc", ASK, ""},
+		{"git log >\"$HOME/log\"", ASK, ""},
+		{"git log && go vet ./... >|/etc/passwd", ASK, ""},
+		{"go vet './... > /tmp/x'", ALLOW, "go vet *"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			action, rule := c.Evaluate(tt.command)
+			if action != tt.wantAction || rule != tt.wantRule {
+				t.Errorf("Evaluate() = %s, %q, want %s, %q", action, rule, tt.wantAction, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestProjectCannotAllow(t *testing.T) {
+	project := Policy{Default: ALLOW, Rules: []Rule{
+		{Pattern: "*", Action: ALLOW},
+		{Pattern: "git push*", Action: DENY},
+	}}
+	tests := []struct {
+		name    string
+		global  Policy
+		command string
+		want    Action
+	}{
+		{"global ask", Policy{Default: ASK}, "make", ASK},
+		{"global deny", Policy{Default: ASK, Rules: []Rule{{Pattern: "rm *", Action: DENY}}}, "rm -r build", DENY},
+		{"global deny default", Policy{Default: DENY}, "make", DENY},
+		{"global without default", Policy{}, "make", ASK},
+		{"project deny", Policy{Default: ALLOW}, "git push origin main", DENY},
+		{"trusted project", Policy{Default: ASK, TrustProject: true}, "make", ALLOW},
+		{"trusted project with global deny", Policy{Rules: []Rule{{Pattern: "rm *", Action: DENY}}, TrustProject: true}, "rm -r build", DENY},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewProjectChecker(tt.global, project)
+			if action, _ := c.Evaluate(tt.command); action != tt.want {
+				t.Errorf("Evaluate(%q) = %s, want %s", tt.command, action, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultAction(t *testing.T) {
+	c := NewChecker(Policy{Default: ALLOW}, Policy{Default: DENY})
+	if action, _ := c.Evaluate("make"); action != DENY {
+		t.Errorf("Evaluate() = %s, want the strictest default %s", action, DENY)
+	}
+	c = NewChecker(Policy{Default: ALLOW})
+	if action, _ := c.Evaluate("make"); action != ALLOW {
+		t.Errorf("Evaluate() = %s, want %s", action, ALLOW)
+	}
+	c = NewChecker(Policy{Default: DENY})
+	if action, _ := c.Evaluate(" "); action != DENY {
+		t.Errorf("Evaluate() of an empty command = %s, want %s", action, DENY)
+	}
+}
+
+func TestConfirmDenied(t *testing.T) {
+	c := NewChecker(Policy{Rules: []Rule{{Pattern: "rm *", Action: DENY}}})
+	err := c.Confirm("rm -r build")
+	denied, ok := err.(*DeniedError)
+	if !ok || denied.Rule != "rm *" {
+		t.Errorf("Confirm() error = %v, want DeniedError by rule 'rm *'", err)
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	got := splitCommand("go vet ./... && go test ./... | tee out; echo \"a;b\" || true &")
+	want := []string{"go vet ./...", "go test ./...", "tee out", "echo \"a;b\"", "true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitCommand() = %q, want %q", got, want)
+	}
+	got = splitCommand("go test ./... 2>&1 | tee out &> log; make >&2 |& cat")
+	want = []string{"go test ./... 2>&1", "tee out &> log", "make >&2", "cat"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestRedirectTargets(t *testing.T) {
+	tests := []struct {
+		part string
+		want []string
+	}{
+		{"go test ./...", []string{}},
+		{"go test ./... 2>&1 >&- <in", []string{}},
+		{"go test > out 2>>err &>both >|clobber", []string{"out", "err", "both", "clobber"}},
+		{"echo '>' \\> x >'my file'", []string{"my file"}},
+		{"echo >\"$HOME\"/x", []string{"$HOME/x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.part, func(t *testing.T) {
+			if got := redirectTargets(tt.part); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redirectTargets() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	p, err := LoadFile(filepath.Join(dir, "missing.json"))
+	if err != nil || len(p.Rules) != 0 {
+		t.Errorf("LoadFile() of missing file = %+v, %v", p, err)
+	}
+	path := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(path, []byte(`{"rules":[{"pattern":"make *","action":"maybe"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(path); err == nil {
+		t.Error("LoadFile() error = nil, want error for unknown action")
+	}
+}
@@ -1,6 +1,7 @@
 package repl
 
 import (
+	"context"
 	"fmt"
 	"sort"
 
@@ -11,11 +12,12 @@ import (
 type CMD struct {
 	name    string
 	info    string
-	command func(arg string) error
+	command func(ctx context.Context, arg string) error
 }
 
 // NewCMD creates a new command instance.
-func NewCMD(name, info string, command func(arg string) error) *CMD {
+// The context passed to the command is cancelled when the user presses Ctrl+C.
+func NewCMD(name, info string, command func(ctx context.Context, arg string) error) *CMD {
 	return &CMD{
 		name:    name,
 		info:    info,
@@ -30,11 +32,11 @@ func (r *REPL) AddCommand(command *CMD) {
 }
 
 // HandleCommand looks up and executes a registered slash command.
-func (r *REPL) HandleCommand(command, arg string) error {
+func (r *REPL) HandleCommand(ctx context.Context, command, arg string) error {
 	for i := range r.commands {
 		if command == r.commands[i].name {
 			logger.Log(logger.INFO, "command '/%s' was entered", command)
-			return r.commands[i].command(arg)
+			return r.commands[i].command(ctx, arg)
 		}
 	}
 	logger.Log(logger.ERROR, "unknown command was entered: /%s", command)
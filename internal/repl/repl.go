@@ -3,6 +3,10 @@
 package repl
 
 import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/thxrsxm/harzmind-code/internal/input"
@@ -15,11 +19,12 @@ import (
 type REPL struct {
 	running  bool
 	commands []CMD
-	main     func(arg string) error
+	main     func(ctx context.Context, arg string) error
 }
 
 // NewREPL initializes and returns a new REPL instance with the given main handler.
-func NewREPL(main func(arg string) error) (*REPL, error) {
+// The context passed to the handler is cancelled when the user presses Ctrl+C.
+func NewREPL(main func(ctx context.Context, arg string) error) (*REPL, error) {
 	r := &REPL{
 		running:  false,
 		commands: []CMD{},
@@ -30,13 +35,13 @@ func NewREPL(main func(arg string) error) (*REPL, error) {
 	r.AddCommand(NewCMD(
 		"help",
 		"List all commands",
-		func(arg string) error { return r.PrintHelp() },
+		func(ctx context.Context, arg string) error { return r.PrintHelp() },
 	))
 	// /exit
 	r.AddCommand(NewCMD(
 		"exit",
 		"End the conversation",
-		func(arg string) error { return r.ExitREPL() },
+		func(ctx context.Context, arg string) error { return r.ExitREPL() },
 	))
 	return r, nil
 }
@@ -90,10 +95,11 @@ func (r *REPL) Run() {
 				if len(parts) == 2 {
 					arg = parts[1]
 				}
-				err := r.HandleCommand(strings.ToLower(parts[0]), arg)
+				err := r.interruptible(func(ctx context.Context) error {
+					return r.HandleCommand(ctx, strings.ToLower(parts[0]), arg)
+				})
 				if err != nil {
-					output.PrintfError("%v\n", err)
-					logger.Log(logger.ERROR, "%v", err)
+					printError(err)
 				}
 			} else {
 				output.PrintlnError("unknown command")
@@ -101,11 +107,30 @@ func (r *REPL) Run() {
 			continue
 		}
 		// Handle main
-		if err := r.main(input); err != nil {
+		if err := r.interruptible(func(ctx context.Context) error { return r.main(ctx, input) }); err != nil {
 			output.Println()
-			output.PrintfError("%v\n", err)
-			logger.Log(logger.ERROR, "%v", err)
+			printError(err)
 			continue
 		}
 	}
 }
+
+// interruptible runs fn with a context that is cancelled on Ctrl+C (SIGINT).
+// While fn is running, the interrupt only cancels the context instead of terminating the process.
+func (r *REPL) interruptible(fn func(ctx context.Context) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return fn(ctx)
+}
+
+// printError prints and logs an error returned by a command or the main handler.
+// Cancellations by the user are reported as a warning.
+func printError(err error) {
+	if errors.Is(err, context.Canceled) {
+		output.PrintlnWarning("request cancelled")
+		logger.Log(logger.WARNING, "%s", "request cancelled by user")
+		return
+	}
+	output.PrintfError("%v\n", err)
+	logger.Log(logger.ERROR, "%v", err)
+}
@@ -0,0 +1,122 @@
+package secrets
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pathPrefix marks a line of the allowlist as a file pattern.
+const pathPrefix string = "path:"
+
+// sensitiveFiles are the patterns of file names that are blocked by default.
+var sensitiveFiles []string = []string{
+	".env",
+	".env.*",
+	"*.pem",
+	"*.key",
+	"*.p12",
+	"*.pfx",
+	"*.jks",
+	"*.keystore",
+	"id_rsa",
+	"id_dsa",
+	"id_ecdsa",
+	"id_ed25519",
+	"credentials",
+	"credentials.json",
+	".netrc",
+	".npmrc",
+	".pypirc",
+	".pgpass",
+	"*.tfstate",
+}
+
+// templateSuffixes are the suffixes of `.env` files that contain no secrets.
+var templateSuffixes []string = []string{".example", ".sample", ".template", ".dist"}
+
+// Allowlist lists the values that are not secrets and the files that are neither blocked nor redacted.
+type Allowlist struct {
+	values map[string]bool
+	paths  []string
+}
+
+// LoadAllowlist reads an allowlist. Every line is either a value that is never redacted
+// or, with the prefix `path:`, a pattern of files that are sent unchanged (e.g. `path:testdata/*.pem`).
+// Patterns without a slash match the file name. Empty lines and lines starting with `#` are skipped.
+// A missing file results in an empty allowlist.
+func LoadAllowlist(name string) (*Allowlist, error) {
+	a := &Allowlist{values: map[string]bool{}}
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return a, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if pattern, ok := strings.CutPrefix(line, pathPrefix); ok {
+			a.paths = append(a.paths, strings.TrimSpace(pattern))
+		} else {
+			a.values[line] = true
+		}
+	}
+	return a, scanner.Err()
+}
+
+// AllowsValue reports whether the value is on the allowlist.
+func (a *Allowlist) AllowsValue(value string) bool {
+	return a.values[value]
+}
+
+// AllowsPath reports whether the file matches a pattern of the allowlist.
+func (a *Allowlist) AllowsPath(name string) bool {
+	for _, pattern := range a.paths {
+		if matchPath(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Blocked reports whether the file has a sensitive name and is not on the allowlist.
+func (a *Allowlist) Blocked(name string) bool {
+	return IsSensitiveFile(name) && !a.AllowsPath(name)
+}
+
+// IsSensitiveFile reports whether the file name is known to contain secrets, like `.env` or `id_rsa`.
+// Templates like `.env.example` are not sensitive.
+func IsSensitiveFile(name string) bool {
+	base := path.Base(filepath.ToSlash(name))
+	for _, suffix := range templateSuffixes {
+		if strings.HasPrefix(base, ".env") && strings.HasSuffix(base, suffix) {
+			return false
+		}
+	}
+	for _, pattern := range sensitiveFiles {
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath matches a file against a pattern, a pattern without a slash matches the file name.
+func matchPath(pattern, name string) bool {
+	name = path.Clean(filepath.ToSlash(name))
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(name)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
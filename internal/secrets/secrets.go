@@ -0,0 +1,261 @@
+// Package secrets detects secrets in file contents and messages and replaces them
+// with placeholders before anything is sent to an API.
+//
+// Secrets are found by their format (API keys, tokens, private key blocks, JWTs) and by
+// assignments of high-entropy values to names like `password` or `api_key`. A Redactor
+// replaces every distinct secret with a stable placeholder such as `[REDACTED:github-token:1]`
+// and can restore the secrets in text written by the model. Files with known-sensitive names
+// (e.g. `.env`, `id_rsa`) are blocked entirely. False positives are listed in an Allowlist.
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Secret kinds.
+const (
+	KIND_AWS_ACCESS_KEY string = "aws-access-key"
+	KIND_GITHUB_TOKEN   string = "github-token"
+	KIND_OPENAI_KEY     string = "openai-key"
+	KIND_GOOGLE_API_KEY string = "google-api-key"
+	KIND_SLACK_TOKEN    string = "slack-token"
+	KIND_PRIVATE_KEY    string = "private-key"
+	KIND_JWT            string = "jwt"
+	KIND_ASSIGNMENT     string = "secret-assignment"
+)
+
+// minEntropy is the minimum Shannon entropy in bits per character of an assigned secret.
+const minEntropy float64 = 3.5
+
+// detector finds secrets of a kind. The secret is the first submatch if the pattern has one.
+type detector struct {
+	kind    string
+	pattern *regexp.Regexp
+}
+
+// detectors are applied in order, the private key blocks first as they contain other matches.
+var detectors []detector = []detector{
+	{KIND_PRIVATE_KEY, regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{KIND_AWS_ACCESS_KEY, regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{KIND_GITHUB_TOKEN, regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{KIND_OPENAI_KEY, regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`)},
+	{KIND_GOOGLE_API_KEY, regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{KIND_SLACK_TOKEN, regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{KIND_JWT, regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{KIND_ASSIGNMENT, regexp.MustCompile(`(?i)[A-Za-z0-9_.-]*(?:secret|token|passw(?:or)?d|pwd|api_?key|access_?key|private_?key|credential|auth)[A-Za-z0-9_.-]*["']?\s*(?::=|=|:)\s*["']?([A-Za-z0-9+/=_.~-]{16,})(?:["'\s,;]|$)`)},
+}
+
+// placeholderPattern matches the placeholders created by a Redactor.
+var placeholderPattern *regexp.Regexp = regexp.MustCompile(`\[REDACTED:([a-z-]+):(\d+)\]`)
+
+// Finding is a secret found in a source.
+type Finding struct {
+	Kind string
+	// Source is the path of the file or a description like `message`.
+	Source      string
+	Placeholder string
+}
+
+// Redactor replaces secrets with placeholders. The same secret always gets the same placeholder,
+// so that the placeholders are stable across the turns of a conversation.
+type Redactor struct {
+	placeholders map[string]string
+	secrets      map[string]string
+	counts       map[string]int
+	allow        *Allowlist
+}
+
+// NewRedactor creates a Redactor with an empty allowlist.
+func NewRedactor() *Redactor {
+	return &Redactor{
+		placeholders: map[string]string{},
+		secrets:      map[string]string{},
+		counts:       map[string]int{},
+		allow:        &Allowlist{},
+	}
+}
+
+// SetAllowlist sets the allowlist of the values and files that are not redacted.
+func (r *Redactor) SetAllowlist(allow *Allowlist) {
+	r.allow = allow
+}
+
+// Redact replaces the secrets in the text of the source with placeholders.
+// Sources on the allowlist are returned unchanged.
+func (r *Redactor) Redact(source, text string) (string, []Finding) {
+	if r.allow.AllowsPath(source) {
+		return text, nil
+	}
+	findings := []Finding{}
+	for _, d := range detectors {
+		text = replaceSubmatch(d.pattern, text, func(secret string) string {
+			if r.allow.AllowsValue(secret) || !plausible(d.kind, secret) {
+				return secret
+			}
+			placeholder := r.placeholder(d.kind, secret)
+			findings = append(findings, Finding{Kind: d.kind, Source: source, Placeholder: placeholder})
+			return placeholder
+		})
+	}
+	return text, findings
+}
+
+// Restore replaces the placeholders in the text with the secrets they stand for.
+func (r *Redactor) Restore(text string) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if secret, ok := r.secrets[placeholder]; ok {
+			return secret
+		}
+		return placeholder
+	})
+}
+
+// RestoreJSON replaces the placeholders in JSON text, like the arguments of a tool call,
+// with the secrets they stand for, escaped as JSON string content.
+func (r *Redactor) RestoreJSON(text string) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		secret, ok := r.secrets[placeholder]
+		if !ok {
+			return placeholder
+		}
+		quoted, _ := json.Marshal(secret)
+		return string(quoted[1 : len(quoted)-1])
+	})
+}
+
+// Reserve continues the numbering of the placeholders after the ones found in the text,
+// e.g. the messages of a resumed conversation. Their secrets are unknown, so they cannot be restored,
+// but new secrets never get the placeholder of an old one.
+func (r *Redactor) Reserve(text string) {
+	for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		n, err := strconv.Atoi(m[2])
+		if err == nil && n > r.counts[m[1]] {
+			r.counts[m[1]] = n
+		}
+	}
+}
+
+// placeholder returns the placeholder of a secret, creating it for a new secret.
+func (r *Redactor) placeholder(kind, secret string) string {
+	if p, ok := r.placeholders[secret]; ok {
+		return p
+	}
+	r.counts[kind]++
+	p := fmt.Sprintf("[REDACTED:%s:%d]", kind, r.counts[kind])
+	r.placeholders[secret] = p
+	r.secrets[p] = secret
+	return p
+}
+
+// replaceSubmatch replaces the first submatch (or the whole match without submatches)
+// of every match of the pattern with the result of repl.
+func replaceSubmatch(pattern *regexp.Regexp, text string, repl func(string) string) string {
+	matches := pattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) > 2 {
+			start, end = m[2], m[3]
+		}
+		sb.WriteString(text[last:start])
+		sb.WriteString(repl(text[start:end]))
+		last = end
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// plausible reports whether a match of the kind is likely a secret.
+// Assigned values must mix letters and digits and have a high entropy.
+func plausible(kind, value string) bool {
+	if kind != KIND_ASSIGNMENT {
+		return true
+	}
+	hasLetter := strings.ContainsAny(value, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	hasDigit := strings.ContainsAny(value, "0123456789")
+	return hasLetter && hasDigit && entropy(value) >= minEntropy
+}
+
+// entropy returns the Shannon entropy of the string in bits per character.
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	n := 0
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+	e := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(n)
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+// Summary returns a one-line summary of the findings,
+// e.g. `2 secrets (aws-access-key in config.yaml, jwt in message)`.
+func Summary(findings []Finding) string {
+	parts := []string{}
+	seen := map[string]bool{}
+	for _, f := range findings {
+		part := fmt.Sprintf("%s in %s", f.Kind, f.Source)
+		if !seen[part] {
+			seen[part] = true
+			parts = append(parts, part)
+		}
+	}
+	sort.Strings(parts)
+	noun := "secrets"
+	if len(findings) == 1 {
+		noun = "secret"
+	}
+	return fmt.Sprintf("%d %s (%s)", len(findings), noun, strings.Join(parts, ", "))
+}
+
+// maxPlaceholderLength is the maximum length of a placeholder held back by a StreamRestorer.
+const maxPlaceholderLength int = 64
+
+// StreamRestorer restores the placeholders in a stream of text fragments.
+// The end of a fragment that may be the start of a placeholder is held back until the next fragment.
+type StreamRestorer struct {
+	redactor *Redactor
+	emit     func(string)
+	pending  string
+}
+
+// NewStreamRestorer creates a StreamRestorer that passes the restored text to emit.
+func (r *Redactor) NewStreamRestorer(emit func(string)) *StreamRestorer {
+	return &StreamRestorer{redactor: r, emit: emit}
+}
+
+// Write restores the placeholders of the fragment and passes the text to emit,
+// except for an incomplete placeholder at its end.
+func (s *StreamRestorer) Write(fragment string) {
+	text := s.pending + fragment
+	cut := len(text)
+	if i := strings.LastIndexByte(text, '['); i >= 0 && !strings.Contains(text[i:], "]") && len(text)-i < maxPlaceholderLength {
+		cut = i
+	}
+	s.pending = text[cut:]
+	if cut > 0 {
+		s.emit(s.redactor.Restore(text[:cut]))
+	}
+}
+
+// Flush passes the held back text to emit.
+func (s *StreamRestorer) Flush() {
+	if len(s.pending) > 0 {
+		s.emit(s.pending)
+		s.pending = ""
+	}
+}
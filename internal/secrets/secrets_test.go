@@ -0,0 +1,128 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Fake secrets of the supported formats, assembled so that the source contains none.
+var (
+	awsKey      = "AKIA" + "IOSFODNN7EXAMPLE"
+	githubToken = "ghp_" + strings.Repeat("aB3dE6", 6)
+	openAIKey   = "sk-proj-" + "Xy7Qw2Lm9Rt4Vb8Np3Ks6Zd1"
+	jwt         = "eyJhbGciOiJIUzI1NiJ9" + ".eyJzdWIiOiIxMjM0NTY3ODkwIn0" + ".dozjgNryP4J3jVmNHl0w5N_XgL0n3I9PlFUP0THsR8U"
+	privateKey  = "-----BEGIN RSA " + "PRIVATE KEY-----\nMIIEpAIBAAKCAQEA\n-----END RSA PRIVATE KEY-----"
+)
+
+func TestRedact(t *testing.T) {
+	r := NewRedactor()
+	text := "aws: " + awsKey + "\n" +
+		"token " + githubToken + " and " + openAIKey + "\n" +
+		"Authorization: Bearer " + jwt + "\n" +
+		privateKey + "\n" +
+		`DB_PASSWORD="x9Fq2LmZ7pW4tR8v"` + "\n" +
+		"again " + awsKey + "\n"
+	got, findings := r.Redact("config.yaml", text)
+	want := "aws: [REDACTED:aws-access-key:1]\n" +
+		"token [REDACTED:github-token:1] and [REDACTED:openai-key:1]\n" +
+		"Authorization: Bearer [REDACTED:jwt:1]\n" +
+		"[REDACTED:private-key:1]\n" +
+		`DB_PASSWORD="[REDACTED:secret-assignment:1]"` + "\n" +
+		"again [REDACTED:aws-access-key:1]\n"
+	if got != want {
+		t.Errorf("Redact() = %q, want %q", got, want)
+	}
+	if len(findings) != 7 {
+		t.Errorf("Redact() findings = %d, want 7", len(findings))
+	}
+	if restored := r.Restore(got); restored != text {
+		t.Errorf("Restore() = %q, want %q", restored, text)
+	}
+	// The placeholders are stable
+	if again, _ := r.Redact("message", "use "+awsKey); again != "use [REDACTED:aws-access-key:1]" {
+		t.Errorf("Redact() again = %q", again)
+	}
+	if got := r.RestoreJSON(`{"content":"[REDACTED:private-key:1]"}`); !strings.Contains(got, `KEY-----\nMIIE`) {
+		t.Errorf("RestoreJSON() = %q", got)
+	}
+}
+
+func TestRedactIgnoresCode(t *testing.T) {
+	r := NewRedactor()
+	text := "token := strings.TrimSpace(os.Getenv(\"HZM_TOKEN\"))\n" +
+		"tokenizer = tiktoken.GetEncoding(\"cl100k_base\")\n" +
+		"apiKeyEnvironmentVariable = \"HARZMIND_API_KEY_VARIABLE\"\n" +
+		"password: changeme\n"
+	if got, findings := r.Redact("main.go", text); got != text || len(findings) != 0 {
+		t.Errorf("Redact() = %q, %v", got, findings)
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "allow")
+	content := "# false positives\n" + awsKey + "\npath:testdata/*.pem\npath:.env.local\n"
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	allow, err := LoadAllowlist(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRedactor()
+	r.SetAllowlist(allow)
+	if got, _ := r.Redact("a.go", awsKey+" "+githubToken); got != awsKey+" [REDACTED:github-token:1]" {
+		t.Errorf("Redact() with allowlist = %q", got)
+	}
+	if got, _ := r.Redact("testdata/key.pem", privateKey); got != privateKey {
+		t.Errorf("Redact() of an allowed file = %q", got)
+	}
+	tests := map[string]bool{
+		".env":             true,
+		"config/.env.prod": true,
+		".env.local":       false,
+		".env.example":     false,
+		"id_rsa":           true,
+		"certs/server.pem": true,
+		"testdata/key.pem": false,
+		"main.go":          false,
+	}
+	for path, want := range tests {
+		if got := allow.Blocked(path); got != want {
+			t.Errorf("Blocked(%q) = %t, want %t", path, got, want)
+		}
+	}
+	if missing, err := LoadAllowlist(filepath.Join(t.TempDir(), "missing")); err != nil || !missing.Blocked(".env") {
+		t.Errorf("LoadAllowlist() of a missing file = %v, %v", missing, err)
+	}
+}
+
+func TestStreamRestorer(t *testing.T) {
+	r := NewRedactor()
+	redacted, _ := r.Redact("message", "key "+awsKey+" and "+githubToken)
+	var sb strings.Builder
+	restorer := r.NewStreamRestorer(func(s string) { sb.WriteString(s) })
+	// Split the placeholders across the fragments
+	for i := 0; i < len(redacted); i += 5 {
+		restorer.Write(redacted[i:min(i+5, len(redacted))])
+	}
+	restorer.Write(" [unfinished")
+	restorer.Flush()
+	if want := "key " + awsKey + " and " + githubToken + " [unfinished"; sb.String() != want {
+		t.Errorf("StreamRestorer = %q, want %q", sb.String(), want)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	r := NewRedactor()
+	r.Reserve("old [REDACTED:aws-access-key:2] and [REDACTED:jwt:1]")
+	got, _ := r.Redact("message", awsKey+" "+githubToken)
+	if want := "[REDACTED:aws-access-key:3] [REDACTED:github-token:1]"; got != want {
+		t.Errorf("Redact() after Reserve() = %q, want %q", got, want)
+	}
+	// Unknown placeholders are kept
+	if got := r.Restore("[REDACTED:aws-access-key:2]"); got != "[REDACTED:aws-access-key:2]" {
+		t.Errorf("Restore() of a reserved placeholder = %q", got)
+	}
+}
@@ -0,0 +1,168 @@
+// Package session persists chat sessions as JSON files in the project's sessions directory,
+// so that conversations can be listed, resumed, renamed and deleted across application runs.
+package session
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/llmx"
+)
+
+// maxTitleLength is the maximum length of a title derived from the first message.
+const maxTitleLength int = 60
+
+// Session represents a saved conversation.
+// Messages contains the history without the system prompt, which is rebuilt on every message.
+type Session struct {
+	ID       string        `json:"id"`
+	Title    string        `json:"title"`
+	Created  time.Time     `json:"created"`
+	Updated  time.Time     `json:"updated"`
+	Account  string        `json:"account"`
+	Model    string        `json:"model"`
+	Messages []api.Message `json:"messages"`
+	// Branch is the checked out branch, Branches contains the other forks of the history.
+	Branch   string                 `json:"branch,omitempty"`
+	Branches map[string]llmx.Branch `json:"branches,omitempty"`
+	Turns    []llmx.Turn            `json:"turns"`
+	// Tokens is the size of the conversation in tokens as of the last turn.
+	Tokens int `json:"tokens"`
+	// Usage is the total token usage of all turns.
+	Usage api.Usage `json:"usage"`
+}
+
+// New creates a new session whose ID is derived from the current time.
+// A random suffix keeps the IDs of sessions created within the same second apart.
+func New() *Session {
+	now := time.Now()
+	return &Session{
+		ID:      fmt.Sprintf("%s-%06x", now.Format("20060102-150405"), rand.IntN(1<<24)),
+		Created: now,
+		Updated: now,
+	}
+}
+
+// Update stores the current state of the conversation in the session.
+// The title is derived from the first user message unless it was set before.
+func (s *Session) Update(account, model string, l *llmx.LLMx) {
+	s.Updated = time.Now()
+	s.Account = account
+	s.Model = model
+	s.Messages = l.GetMessages()
+	s.Branch = l.GetBranch()
+	s.Branches = l.GetBranches()
+	s.Turns = l.GetTurns()
+	s.Tokens = l.GetTokens()
+	s.Usage = llmx.TotalUsage(s.Turns)
+	if len(s.Title) == 0 {
+		for _, msg := range s.Messages {
+			if msg.Role == "user" {
+				s.Title = makeTitle(msg.Content)
+				break
+			}
+		}
+	}
+}
+
+// Restore replaces the conversation of the LLM client with the one of the session.
+func (s *Session) Restore(l *llmx.LLMx) {
+	l.Restore(s.Messages, s.Turns, s.Tokens)
+	l.RestoreBranches(s.Branch, s.Branches)
+}
+
+// Save writes the session to `<dir>/<id>.json`, creating the directory if necessary.
+func (s *Session) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path(dir, s.ID), data, 0644)
+}
+
+// Load reads the session with the ID from the directory.
+func Load(dir, id string) (*Session, error) {
+	data, err := os.ReadFile(path(dir, id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("session %s not found", id)
+	} else if err != nil {
+		return nil, err
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid session %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+// List returns all sessions of the directory, most recently updated first.
+// A missing directory yields no sessions.
+func List(dir string) ([]*Session, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	sessions := []*Session{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		s, err := Load(dir, id)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	slices.SortFunc(sessions, func(a, b *Session) int { return cmp.Compare(b.Updated.UnixNano(), a.Updated.UnixNano()) })
+	return sessions, nil
+}
+
+// Latest returns the most recently updated session of the directory.
+func Latest(dir string) (*Session, error) {
+	sessions, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, fmt.Errorf("no sessions")
+	}
+	return sessions[0], nil
+}
+
+// Delete removes the session with the ID from the directory.
+func Delete(dir, id string) error {
+	err := os.Remove(path(dir, id))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("session %s not found", id)
+	}
+	return err
+}
+
+// path returns the file path of the session with the ID.
+// The ID is reduced to its base name, so it cannot address files outside of the directory.
+func path(dir, id string) string {
+	return filepath.Join(dir, filepath.Base(id)+".json")
+}
+
+// makeTitle shortens a message to a single-line title.
+func makeTitle(msg string) string {
+	title := strings.Join(strings.Fields(msg), " ")
+	if len([]rune(title)) > maxTitleLength {
+		title = string([]rune(title)[:maxTitleLength-3]) + "..."
+	}
+	return title
+}
@@ -0,0 +1,54 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thxrsxm/harzmind-code/internal/agent"
+	"github.com/thxrsxm/harzmind-code/internal/api"
+	"github.com/thxrsxm/harzmind-code/internal/llmx"
+	"github.com/thxrsxm/harzmind-code/internal/policy"
+)
+
+func TestSaveLoadList(t *testing.T) {
+	dir := t.TempDir()
+	older := &Session{ID: "older", Updated: time.Now().Add(-time.Hour)}
+	if err := older.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	s := New()
+	if other := New(); other.ID == s.ID {
+		t.Errorf("New() created the ID %q twice", s.ID)
+	}
+	messages := []api.Message{{Role: "user", Content: "Explain\n  main.go"}, {Role: "assistant", Content: "It starts the app."}}
+	turns := []llmx.Turn{{Model: "gpt-4o", Usage: api.Usage{PromptTokens: 100, CompletionTokens: 10}}}
+	l := llmx.NewLLMx(llmx.DefaultCompactionPolicy(), llmx.DefaultAgentPolicy(), agent.Env{Commands: policy.NewChecker()})
+	l.Restore(messages, turns, 110)
+	s.Update("openai", "gpt-4o", l)
+	if err := s.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "Explain main.go" {
+		t.Errorf("Title = %q, want %q", s.Title, "Explain main.go")
+	}
+	loaded, err := Load(dir, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Messages) != 2 || loaded.Usage.PromptTokens != 100 || loaded.Tokens != 110 || loaded.Branch != llmx.DEFAULT_BRANCH {
+		t.Errorf("Load() = %+v", loaded)
+	}
+	sessions, err := List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != s.ID {
+		t.Errorf("List() returned %d sessions, first %q", len(sessions), sessions[0].ID)
+	}
+	if err := Delete(dir, "older"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir, "older"); err == nil {
+		t.Errorf("Load() of a deleted session succeeded")
+	}
+}
@@ -0,0 +1,387 @@
+// Package snapshot keeps a content-addressed store of working-tree snapshots,
+// one per turn, so that file changes made since a turn can be listed and rolled back.
+//
+// File contents are stored once under `objects/` by their SHA-256 hash,
+// every snapshot is a manifest under `manifests/` mapping paths to hashes.
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thxrsxm/harzmind-code/internal/common"
+	"github.com/thxrsxm/harzmind-code/internal/edits"
+	"github.com/thxrsxm/harzmind-code/internal/secrets"
+)
+
+// maxSnapshots is the number of snapshots kept, older ones are pruned.
+const maxSnapshots int = 100
+
+// maxFileBytes is the maximum size of a file whose content is stored in a snapshot.
+var maxFileBytes int64 = 16 * 1024 * 1024
+
+// Manifest describes the working tree at the start of a turn.
+type Manifest struct {
+	Turn int       `json:"turn"`
+	Time time.Time `json:"time"`
+	// Files maps the paths of the files to the hashes of their contents.
+	Files map[string]string `json:"files"`
+	// Untracked are the files whose contents were not stored (sensitive, too large or unreadable).
+	// They are never changed by a rollback.
+	Untracked []string `json:"untracked,omitempty"`
+	// written are the hashes of the contents stored by Capture that were not stored before.
+	// They are removed by Discard unless the manifest was saved.
+	written []string
+}
+
+// ChangeKind is the kind of change of a file.
+type ChangeKind string
+
+const (
+	ADDED    ChangeKind = "added"
+	MODIFIED ChangeKind = "modified"
+	DELETED  ChangeKind = "deleted"
+)
+
+// Change is a file changed since a snapshot, with the number of added and removed lines.
+type Change struct {
+	Path    string
+	Kind    ChangeKind
+	Added   int
+	Removed int
+}
+
+// Store is a snapshot store in a directory.
+type Store struct {
+	dir string
+}
+
+// NewStore returns the snapshot store in the directory.
+// The directory is created with the first snapshot.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Capture stores the contents of the files and returns their manifest, which becomes a snapshot with Save.
+// A manifest that is not saved must be discarded with Discard to remove its contents again.
+// The paths must be all files of the working tree, independent of the limits of the codebase (see codebase.ListFiles).
+// Sensitive files that are not on the secrets allowlist, files larger than maxFileBytes and unreadable files
+// are listed as untracked.
+func (s *Store) Capture(paths []string) (*Manifest, error) {
+	m := &Manifest{Time: time.Now(), Files: map[string]string{}}
+	allow := loadAllowlist()
+	for _, path := range paths {
+		content, ok := readContent(path, allow)
+		if !ok {
+			m.Untracked = append(m.Untracked, filepath.ToSlash(path))
+			continue
+		}
+		hash, created, err := s.writeObject(content)
+		if err != nil {
+			s.Discard(m)
+			return nil, err
+		}
+		if created {
+			m.written = append(m.written, hash)
+		}
+		m.Files[filepath.ToSlash(path)] = hash
+	}
+	return m, nil
+}
+
+// Discard removes the contents that were stored for the manifest by Capture and are not part of
+// a saved snapshot. It does nothing for a saved manifest or nil.
+func (s *Store) Discard(m *Manifest) error {
+	if m == nil {
+		return nil
+	}
+	var errs []error
+	for _, hash := range m.written {
+		if err := os.Remove(s.objectPath(hash)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	m.written = nil
+	return errors.Join(errs...)
+}
+
+// Save stores the captured manifest as the snapshot with the next turn number.
+func (s *Store) Save(m *Manifest) error {
+	turns, err := s.Turns()
+	if err != nil {
+		return err
+	}
+	m.Turn = 1
+	if len(turns) > 0 {
+		m.Turn = turns[len(turns)-1] + 1
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.manifestDir(), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.manifestPath(m.Turn), data, 0644); err != nil {
+		return err
+	}
+	// The contents are referenced by the snapshot now
+	m.written = nil
+	return s.prune(append(turns, m.Turn))
+}
+
+// Load reads the snapshot of a turn.
+func (s *Store) Load(turn int) (*Manifest, error) {
+	data, err := os.ReadFile(s.manifestPath(turn))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no snapshot of turn %d", turn)
+	} else if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid snapshot of turn %d: %w", turn, err)
+	}
+	return &m, nil
+}
+
+// Latest reads the most recent snapshot.
+func (s *Store) Latest() (*Manifest, error) {
+	turns, err := s.Turns()
+	if err != nil {
+		return nil, err
+	}
+	if len(turns) == 0 {
+		return nil, fmt.Errorf("no snapshots")
+	}
+	return s.Load(turns[len(turns)-1])
+}
+
+// Turns returns the sorted turn numbers of all stored snapshots.
+func (s *Store) Turns() ([]int, error) {
+	entries, err := os.ReadDir(s.manifestDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	turns := []int{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if turn, err := strconv.Atoi(name); err == nil {
+			turns = append(turns, turn)
+		}
+	}
+	slices.Sort(turns)
+	return turns, nil
+}
+
+// Changes compares the snapshot with the current files of the working tree and returns the changed files sorted by path.
+// The paths must be all files of the working tree like for Capture. Files that cannot be compared are left out:
+// untracked files and files that cannot be read now are neither modified nor deleted,
+// and a file is only added if it is not part of the snapshot and was changed after it was taken.
+func (s *Store) Changes(m *Manifest, paths []string) ([]Change, error) {
+	changes := []Change{}
+	untracked := map[string]bool{}
+	for _, path := range m.Untracked {
+		untracked[path] = true
+	}
+	present := map[string]bool{}
+	allow := loadAllowlist()
+	for _, p := range paths {
+		path := filepath.ToSlash(p)
+		present[path] = true
+		if untracked[path] {
+			continue
+		}
+		content, ok := readContent(p, allow)
+		if !ok {
+			continue
+		}
+		hash, ok := m.Files[path]
+		if !ok {
+			// A file that is older than the snapshot existed when it was taken
+			if info, err := os.Stat(p); err != nil || !info.ModTime().After(m.Time) {
+				continue
+			}
+			added, _ := edits.CountChanges("", content)
+			changes = append(changes, Change{Path: path, Kind: ADDED, Added: added})
+			continue
+		}
+		if hash == hashContent(content) {
+			continue
+		}
+		old, err := s.readObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		added, removed := edits.CountChanges(old, content)
+		changes = append(changes, Change{Path: path, Kind: MODIFIED, Added: added, Removed: removed})
+	}
+	for path, hash := range m.Files {
+		if present[path] {
+			continue
+		}
+		// The file may still exist, e.g. ignored since the snapshot
+		if _, err := os.Lstat(filepath.FromSlash(path)); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		old, err := s.readObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		_, removed := edits.CountChanges(old, "")
+		changes = append(changes, Change{Path: path, Kind: DELETED, Removed: removed})
+	}
+	slices.SortFunc(changes, func(a, b Change) int { return strings.Compare(a.Path, b.Path) })
+	return changes, nil
+}
+
+// loadAllowlist loads the secrets allowlist of the project, whose files are snapshotted like
+// the others since they can be sent and edited (see secrets.Allowlist). Errors result in an empty allowlist.
+func loadAllowlist() *secrets.Allowlist {
+	allow, _ := secrets.LoadAllowlist(common.PATH_FILE_SECRETS_ALLOWLIST)
+	return allow
+}
+
+// readContent reads the content of a file for a snapshot.
+// It reports false for sensitive files blocked by the allowlist, files larger than maxFileBytes and unreadable files.
+func readContent(path string, allow *secrets.Allowlist) (string, bool) {
+	if allow.Blocked(path) {
+		return "", false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxFileBytes {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Restore reverts the changed files to their state in the snapshot:
+// modified and deleted files are rewritten, added files are removed.
+func (s *Store) Restore(m *Manifest, changes []Change) error {
+	for _, c := range changes {
+		path := filepath.FromSlash(c.Path)
+		if c.Kind == ADDED {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		content, err := s.readObject(m.Files[c.Path])
+		if err != nil {
+			return err
+		}
+		perm := fs.FileMode(0644)
+		if info, err := os.Stat(path); err == nil {
+			perm = info.Mode().Perm()
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prune removes the oldest snapshots beyond maxSnapshots and the contents no longer referenced.
+func (s *Store) prune(turns []int) error {
+	if len(turns) <= maxSnapshots {
+		return nil
+	}
+	for _, turn := range turns[:len(turns)-maxSnapshots] {
+		if err := os.Remove(s.manifestPath(turn)); err != nil {
+			return err
+		}
+	}
+	// Collect the referenced contents
+	referenced := map[string]bool{}
+	for _, turn := range turns[len(turns)-maxSnapshots:] {
+		m, err := s.Load(turn)
+		if err != nil {
+			return err
+		}
+		for hash := range maps.Values(m.Files) {
+			referenced[hash] = true
+		}
+	}
+	// Remove the others
+	return filepath.WalkDir(s.objectDir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !referenced[filepath.Base(filepath.Dir(path))+d.Name()] {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// writeObject stores the content by its hash unless it is already stored.
+// It reports whether the content was stored now.
+func (s *Store) writeObject(content string) (string, bool, error) {
+	hash := hashContent(content)
+	path := s.objectPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", false, err
+	}
+	return hash, true, os.WriteFile(path, []byte(content), 0644)
+}
+
+// readObject reads the content with the hash.
+func (s *Store) readObject(hash string) (string, error) {
+	data, err := os.ReadFile(s.objectPath(hash))
+	if err != nil {
+		return "", fmt.Errorf("reading snapshot content: %w", err)
+	}
+	return string(data), nil
+}
+
+// hashContent returns the hex-encoded SHA-256 hash of the content.
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// manifestDir returns the directory of the manifests.
+func (s *Store) manifestDir() string {
+	return filepath.Join(s.dir, "manifests")
+}
+
+// manifestPath returns the path of the manifest of a turn.
+func (s *Store) manifestPath(turn int) string {
+	return filepath.Join(s.manifestDir(), fmt.Sprintf("%d.json", turn))
+}
+
+// objectDir returns the directory of the contents.
+func (s *Store) objectDir() string {
+	return filepath.Join(s.dir, "objects")
+}
+
+// objectPath returns the path of the content with the hash, fanned out by its first two characters.
+func (s *Store) objectPath(hash string) string {
+	return filepath.Join(s.objectDir(), hash[:2], hash[2:])
+}
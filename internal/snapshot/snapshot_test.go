@@ -0,0 +1,181 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thxrsxm/harzmind-code/internal/common"
+)
+
+// writeFile writes the content to the file or fails the test.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangesAndRestore(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := NewStore("snapshots")
+	writeFile(t, "keep.txt", "same\n")
+	writeFile(t, "edit.txt", "a\nb\n")
+	writeFile(t, "gone.txt", "x\n")
+	m, err := store.Capture([]string{"keep.txt", "edit.txt", "gone.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Turn != 1 {
+		t.Errorf("Turn = %d, want 1", m.Turn)
+	}
+	// Change the working tree
+	writeFile(t, "edit.txt", "a\nB\nc\n")
+	writeFile(t, "new.txt", "n\n")
+	os.Remove("gone.txt")
+	loaded, err := store.Load(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := store.Changes(loaded, []string{"keep.txt", "edit.txt", "new.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Path: "edit.txt", Kind: MODIFIED, Added: 2, Removed: 1},
+		{Path: "gone.txt", Kind: DELETED, Removed: 1},
+		{Path: "new.txt", Kind: ADDED, Added: 1},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Changes() = %+v, want %+v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("Changes()[%d] = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+	// Roll back
+	if err := store.Restore(loaded, changes); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{"edit.txt": "a\nb\n", "gone.txt": "x\n"} {
+		if data, _ := os.ReadFile(path); string(data) != content {
+			t.Errorf("%s = %q, want %q", path, data, content)
+		}
+	}
+	if _, err := os.Stat("new.txt"); !os.IsNotExist(err) {
+		t.Errorf("new.txt was not removed")
+	}
+	// Numbering continues
+	m, err = store.Capture(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(m); err != nil || m.Turn != 2 {
+		t.Errorf("Save() = %v, turn %d, want turn 2", err, m.Turn)
+	}
+}
+
+func TestChangesAcrossLimits(t *testing.T) {
+	t.Chdir(t.TempDir())
+	defer func(max int64) { maxFileBytes = max }(maxFileBytes)
+	maxFileBytes = 10
+	store := NewStore("snapshots")
+	writeFile(t, "shrink.txt", "larger than the limit\n")
+	writeFile(t, "grow.txt", "small\n")
+	writeFile(t, ".env", "TOKEN=x\n")
+	writeFile(t, "old.txt", "old\n")
+	m, err := store.Capture([]string{"shrink.txt", "grow.txt", ".env"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A file that existed before the snapshot, but was not part of it (e.g. ignored back then)
+	past := m.Time.Add(-time.Hour)
+	if err := os.Chtimes("old.txt", past, past); err != nil {
+		t.Fatal(err)
+	}
+	// Cross the limit in both directions
+	writeFile(t, "shrink.txt", "small\n")
+	writeFile(t, "grow.txt", "larger than the limit\n")
+	writeFile(t, ".env", "TOKEN=y\n")
+	changes, err := store.Changes(m, []string{"shrink.txt", "grow.txt", ".env", "old.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Changes() = %+v, want none", changes)
+	}
+	if err := store.Restore(m, changes); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{"shrink.txt": "small\n", "grow.txt": "larger than the limit\n", ".env": "TOKEN=y\n", "old.txt": "old\n"} {
+		if data, _ := os.ReadFile(path); string(data) != content {
+			t.Errorf("%s = %q, want %q", path, data, content)
+		}
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := NewStore("snapshots")
+	writeFile(t, "saved.txt", "saved\n")
+	m, err := store.Capture([]string{"saved.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(m); err != nil {
+		t.Fatal(err)
+	}
+	// Discarding a saved snapshot keeps its contents
+	if err := store.Discard(m); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, "failed.txt", "failed turn\n")
+	m, err = store.Capture([]string{"saved.txt", "failed.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Discard(m); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.readObject(hashContent("saved\n")); err != nil {
+		t.Errorf("content of the saved snapshot was removed: %v", err)
+	}
+	if _, err := os.Stat(store.objectPath(hashContent("failed turn\n"))); !os.IsNotExist(err) {
+		t.Errorf("content of the discarded snapshot was kept")
+	}
+}
+
+func TestCaptureAllowlisted(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(filepath.Dir(common.PATH_FILE_SECRETS_ALLOWLIST), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, common.PATH_FILE_SECRETS_ALLOWLIST, "path:test.pem\n")
+	writeFile(t, "test.pem", "fixture\n")
+	writeFile(t, "id_rsa", "key\n")
+	store := NewStore("snapshots")
+	m, err := store.Capture([]string{"test.pem", "id_rsa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Files["test.pem"]; !ok {
+		t.Errorf("allowlisted file is not part of the snapshot")
+	}
+	if !reflect.DeepEqual(m.Untracked, []string{"id_rsa"}) {
+		t.Errorf("Untracked = %v, want [id_rsa]", m.Untracked)
+	}
+	writeFile(t, "test.pem", "changed\n")
+	changes, err := store.Changes(m, []string{"test.pem", "id_rsa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Path != "test.pem" || changes[0].Kind != MODIFIED {
+		t.Errorf("Changes() = %+v, want test.pem modified", changes)
+	}
+}